@@ -46,10 +46,11 @@ func (p *Program) String() string {
 	return out.String()
 }
 
-// LetStatement is the Node for statements like: let x = 5;
+// LetStatement is the Node for statements like: let x = 5; or let x: int = 5;
 type LetStatement struct {
 	Token token.Token // the token.LET token
 	Name  *Identifier
+	Type  *TypeAnnotation // nil without an annotation
 	Value Expression
 }
 
@@ -64,6 +65,9 @@ func (ls *LetStatement) String() string {
 
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -122,6 +126,45 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
+// ImportStatement => import "<path>" as <name>;
+type ImportStatement struct {
+	Token token.Token
+	Path  *StringLiteral
+	Alias *Identifier
+}
+
+func (is *ImportStatement) statementNode() {}
+
+// TokenLiteral is the token string
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(is.TokenLiteral() + " ")
+	out.WriteString("\"" + is.Path.Value + "\"")
+	out.WriteString(" as ")
+	out.WriteString(is.Alias.String())
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// ExportStatement => export let <name> = <expression>;
+type ExportStatement struct {
+	Token     token.Token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode() {}
+
+// TokenLiteral is the token string
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
 //BlockStatement => { <statements> }
 type BlockStatement struct {
 	Token      token.Token
@@ -163,6 +206,17 @@ func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// FloatLiteral => 3.14; eg.
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+// TokenLiteral is the float as a string
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 //StringLiteral => "hello world"; eg.
 type StringLiteral struct {
 	Token token.Token
@@ -174,11 +228,14 @@ func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
-// FunctionLiteral represents a function
+// FunctionLiteral represents a function, ParameterTypes holds the annotation
+// of each parameter, nil where there is none
 type FunctionLiteral struct {
-	Token      token.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token          token.Token
+	Parameters     []*Identifier
+	ParameterTypes []*TypeAnnotation
+	ReturnType     *TypeAnnotation
+	Body           *BlockStatement
 }
 
 //TokenLiteral is the token string
@@ -188,19 +245,88 @@ func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		if typ := fl.ParameterType(i); typ != nil {
+			params = append(params, p.String()+": "+typ.String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// ParameterType is the annotation of the i'th parameter, or nil
+func (fl *FunctionLiteral) ParameterType(i int) *TypeAnnotation {
+	if i < len(fl.ParameterTypes) {
+		return fl.ParameterTypes[i]
+	}
+	return nil
+}
+
+// MacroLiteral is macro(a, b) { ... }, bound by a let at the top level it
+// defines a macro that is expanded before the program runs
+type MacroLiteral struct {
+	Token      token.Token // the token.MACRO token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+//TokenLiteral is the token string
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) String() string {
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+	return ml.TokenLiteral() + "(" + strings.Join(params, ", ") + ") " + ml.Body.String()
+}
+
+// Quoted is the argument of quote(x), which is code rather than a value.
+// ok is false for anything but a call to quote with one argument.
+func Quoted(node Node) (arg Expression, ok bool) {
+	return specialCall(node, "quote")
+}
+
+// Unquoted is the argument of unquote(x), which is evaluated inside quoted
+// code
+func Unquoted(node Node) (arg Expression, ok bool) {
+	return specialCall(node, "unquote")
+}
+
+func specialCall(node Node, name string) (Expression, bool) {
+	call, ok := node.(*CallExpression)
+	if !ok || len(call.Arguments) != 1 {
+		return nil, false
+	}
+	ident, ok := call.Function.(*Identifier)
+	if !ok || ident.Value != name {
+		return nil, false
+	}
+	return call.Arguments[0], true
+}
+
+// TypeAnnotation is the type after a name: let x: int or fn(a: int) -> bool.
+// The evaluator ignores them, they are for the type checker.
+type TypeAnnotation struct {
+	Token token.Token
+	Name  string
+}
+
+// TokenLiteral is the token string
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+func (ta *TypeAnnotation) String() string       { return ta.Name }
+
 //ArrayLiteral is an array, mixed types are ok
 type ArrayLiteral struct {
 	Token    token.Token
@@ -387,10 +513,60 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
-//HashLiteral is the dictonary
+// MemberExpression is for looking up a name in a module or hash: <expression>.<name>
+type MemberExpression struct {
+	Token    token.Token
+	Object   Expression
+	Property *Identifier
+}
+
+// TokenLiteral is string value of the token
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) String() string {
+	return me.Object.String() + "." + me.Property.String()
+}
+
+// SliceExpression is for slicing arrays and strings: <expression>[<start>:<end>:<step>]
+// any of Start, End and Step may be nil when left out
+type SliceExpression struct {
+	Token token.Token
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+// TokenLiteral is string value of the token
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+//HashLiteral is the dictonary, Keys holds the keys in source order
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	Keys  []Expression
 }
 
 // HashLiteral is string value of the token
@@ -400,8 +576,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -0,0 +1,286 @@
+package ast
+
+import "reflect"
+
+// Visitor's Visit is called by Walk for each node, when it returns a visitor
+// w the node's children are walked with w and then w.Visit(nil) is called
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk walks the tree rooted at node depth first, visiting children in the
+// order they appear in the source
+func Walk(v Visitor, node Node) {
+	visitors := []Visitor{v}
+	Apply(node, func(c *Cursor) bool {
+		w := visitors[len(visitors)-1].Visit(c.Node())
+		if w == nil {
+			return false
+		}
+		visitors = append(visitors, w)
+		return true
+	}, func(c *Cursor) bool {
+		visitors[len(visitors)-1].Visit(nil)
+		visitors = visitors[:len(visitors)-1]
+		return true
+	})
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect walks the tree rooted at node calling f for each node, the
+// children of a node are skipped when f returns false. After the children
+// f is called with nil.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Cursor is the node Apply is at and where it is in its parent
+type Cursor struct {
+	parent Node
+	name   string
+	index  int
+	node   Node
+	set    func(Node)
+	remove func()
+}
+
+// Node is the current node
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent is the node that holds the current node, nil for the root
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name is the name of the parent's field holding the current node, like
+// "Left" or "Arguments"
+func (c *Cursor) Name() string { return c.name }
+
+// Index is the current node's index when the field is a list, else -1
+func (c *Cursor) Index() int { return c.index }
+
+// Replace puts n where the current node is, it panics if the field can't
+// hold n, like a statement in place of an expression
+func (c *Cursor) Replace(n Node) {
+	c.set(n)
+	c.node = n
+}
+
+// Delete removes the current node from the statements of a program or
+// block, it panics for any other node
+func (c *Cursor) Delete() {
+	if c.remove == nil {
+		panic("ast: Delete of a node that is not in a list of statements")
+	}
+	c.remove()
+}
+
+// ApplyFunc is called by Apply for each node
+type ApplyFunc func(c *Cursor) bool
+
+type abort struct{}
+
+// Apply walks the tree rooted at root depth first, calling pre before a
+// node's children and post after them, either may be nil. When pre returns
+// false the node's children and post are skipped, when post returns false
+// the walk stops. A node replaced in pre has the new node's children walked.
+// Apply returns the root, which may have been replaced.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	parent := &rootNode{node: root}
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(abort); !ok {
+				panic(r)
+			}
+		}
+		result = parent.node
+	}()
+
+	a := &applier{pre: pre, post: post}
+	a.apply(nil, "", -1, root, func(n Node) { parent.node = n }, nil)
+	return parent.node
+}
+
+// Rewrite calls f for every node after its children, replacing the node
+// with what f returns
+func Rewrite(node Node, f func(Node) Node) Node {
+	return Apply(node, nil, func(c *Cursor) bool {
+		if n := f(c.Node()); n != c.Node() {
+			c.Replace(n)
+		}
+		return true
+	})
+}
+
+// Copy returns a deep copy of the tree rooted at node, changing the copy
+// leaves the original as it was
+func Copy(node Node) Node {
+	if isNil(node) {
+		return node
+	}
+	return Apply(node, func(c *Cursor) bool {
+		c.Replace(shallowCopy(c.Node()))
+		return true
+	}, nil)
+}
+
+// shallowCopy copies a node and the lists and maps in it, the nodes they
+// hold are shared until Copy replaces them
+func shallowCopy(n Node) Node {
+	orig := reflect.ValueOf(n).Elem()
+	copied := reflect.New(orig.Type()).Elem()
+	copied.Set(orig)
+	for i := 0; i < copied.NumField(); i++ {
+		field := copied.Field(i)
+		switch {
+		case field.Kind() == reflect.Slice && !field.IsNil():
+			list := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+			reflect.Copy(list, field)
+			field.Set(list)
+		case field.Kind() == reflect.Map && !field.IsNil():
+			m := reflect.MakeMapWithSize(field.Type(), field.Len())
+			for iter := field.MapRange(); iter.Next(); {
+				m.SetMapIndex(iter.Key(), iter.Value())
+			}
+			field.Set(m)
+		}
+	}
+	return copied.Addr().Interface().(Node)
+}
+
+// rootNode holds the root so replacing it works like any other node
+type rootNode struct {
+	node Node
+}
+
+type applier struct {
+	pre, post ApplyFunc
+}
+
+// isNil reports whether a node is missing, a field can hold a nil pointer
+// when a statement failed to parse
+func isNil(n Node) bool {
+	return n == nil || reflect.ValueOf(n).IsNil()
+}
+
+func (a *applier) apply(parent Node, name string, index int, n Node, set func(Node), remove func()) {
+	if isNil(n) {
+		return
+	}
+
+	c := &Cursor{parent: parent, name: name, index: index, node: n, set: set, remove: remove}
+	if a.pre != nil && !a.pre(c) {
+		return
+	}
+	if !isNil(c.node) {
+		a.children(c.node)
+	}
+	if a.post != nil && !a.post(c) {
+		panic(abort{})
+	}
+}
+
+func (a *applier) children(n Node) {
+	switch n := n.(type) {
+	case *Program:
+		a.statements(n, "Statements", &n.Statements)
+	case *BlockStatement:
+		a.statements(n, "Statements", &n.Statements)
+	case *LetStatement:
+		a.apply(n, "Name", -1, n.Name, func(x Node) { n.Name = x.(*Identifier) }, nil)
+		a.apply(n, "Type", -1, n.Type, func(x Node) { n.Type = x.(*TypeAnnotation) }, nil)
+		a.apply(n, "Value", -1, n.Value, func(x Node) { n.Value = x.(Expression) }, nil)
+	case *ReturnStatement:
+		a.apply(n, "ReturnValue", -1, n.ReturnValue, func(x Node) { n.ReturnValue = x.(Expression) }, nil)
+	case *ExpressionStatement:
+		a.apply(n, "Expression", -1, n.Expression, func(x Node) { n.Expression = x.(Expression) }, nil)
+	case *ImportStatement:
+		a.apply(n, "Path", -1, n.Path, func(x Node) { n.Path = x.(*StringLiteral) }, nil)
+		a.apply(n, "Alias", -1, n.Alias, func(x Node) { n.Alias = x.(*Identifier) }, nil)
+	case *ExportStatement:
+		a.apply(n, "Statement", -1, n.Statement, func(x Node) { n.Statement = x.(*LetStatement) }, nil)
+	case *FunctionLiteral:
+		for i := range n.Parameters {
+			i := i
+			a.apply(n, "Parameters", i, n.Parameters[i], func(x Node) { n.Parameters[i] = x.(*Identifier) }, nil)
+			if i < len(n.ParameterTypes) {
+				a.apply(n, "ParameterTypes", i, n.ParameterTypes[i], func(x Node) { n.ParameterTypes[i] = x.(*TypeAnnotation) }, nil)
+			}
+		}
+		a.apply(n, "ReturnType", -1, n.ReturnType, func(x Node) { n.ReturnType = x.(*TypeAnnotation) }, nil)
+		a.apply(n, "Body", -1, n.Body, func(x Node) { n.Body = x.(*BlockStatement) }, nil)
+	case *MacroLiteral:
+		for i := range n.Parameters {
+			i := i
+			a.apply(n, "Parameters", i, n.Parameters[i], func(x Node) { n.Parameters[i] = x.(*Identifier) }, nil)
+		}
+		a.apply(n, "Body", -1, n.Body, func(x Node) { n.Body = x.(*BlockStatement) }, nil)
+	case *ArrayLiteral:
+		a.expressions(n, "Elements", n.Elements)
+	case *HashLiteral:
+		for i := range n.Keys {
+			i := i
+			a.apply(n, "Keys", i, n.Keys[i], func(x Node) {
+				value := n.Pairs[n.Keys[i]]
+				delete(n.Pairs, n.Keys[i])
+				n.Keys[i] = x.(Expression)
+				n.Pairs[n.Keys[i]] = value
+			}, nil)
+			a.apply(n, "Pairs", i, n.Pairs[n.Keys[i]], func(x Node) { n.Pairs[n.Keys[i]] = x.(Expression) }, nil)
+		}
+	case *PrefixExpression:
+		a.apply(n, "Right", -1, n.Right, func(x Node) { n.Right = x.(Expression) }, nil)
+	case *InfixExpression:
+		a.apply(n, "Left", -1, n.Left, func(x Node) { n.Left = x.(Expression) }, nil)
+		a.apply(n, "Right", -1, n.Right, func(x Node) { n.Right = x.(Expression) }, nil)
+	case *IfExpression:
+		a.apply(n, "Condition", -1, n.Condition, func(x Node) { n.Condition = x.(Expression) }, nil)
+		a.apply(n, "Consequence", -1, n.Consequence, func(x Node) { n.Consequence = x.(*BlockStatement) }, nil)
+		a.apply(n, "Alternative", -1, n.Alternative, func(x Node) { n.Alternative = x.(*BlockStatement) }, nil)
+	case *WhileExpression:
+		a.apply(n, "Test", -1, n.Test, func(x Node) { n.Test = x.(Expression) }, nil)
+		a.apply(n, "Body", -1, n.Body, func(x Node) { n.Body = x.(*BlockStatement) }, nil)
+	case *CallExpression:
+		a.apply(n, "Function", -1, n.Function, func(x Node) { n.Function = x.(Expression) }, nil)
+		a.expressions(n, "Arguments", n.Arguments)
+	case *IndexExpression:
+		a.apply(n, "Left", -1, n.Left, func(x Node) { n.Left = x.(Expression) }, nil)
+		a.apply(n, "Index", -1, n.Index, func(x Node) { n.Index = x.(Expression) }, nil)
+	case *SliceExpression:
+		a.apply(n, "Left", -1, n.Left, func(x Node) { n.Left = x.(Expression) }, nil)
+		a.apply(n, "Start", -1, n.Start, func(x Node) { n.Start = x.(Expression) }, nil)
+		a.apply(n, "End", -1, n.End, func(x Node) { n.End = x.(Expression) }, nil)
+		a.apply(n, "Step", -1, n.Step, func(x Node) { n.Step = x.(Expression) }, nil)
+	case *MemberExpression:
+		a.apply(n, "Object", -1, n.Object, func(x Node) { n.Object = x.(Expression) }, nil)
+		a.apply(n, "Property", -1, n.Property, func(x Node) { n.Property = x.(*Identifier) }, nil)
+	}
+	// Identifier, IntegerLiteral, FloatLiteral, StringLiteral, Boolean, Null
+	// and TypeAnnotation have no children
+}
+
+func (a *applier) statements(parent Node, name string, list *[]Statement) {
+	for i := 0; i < len(*list); {
+		at, deleted := i, false
+		a.apply(parent, name, at, (*list)[at], func(x Node) { (*list)[at] = x.(Statement) }, func() {
+			*list = append((*list)[:at], (*list)[at+1:]...)
+			deleted = true
+		})
+		if !deleted {
+			i++
+		}
+	}
+}
+
+func (a *applier) expressions(parent Node, name string, list []Expression) {
+	for i := range list {
+		i := i
+		a.apply(parent, name, i, list[i], func(x Node) { list[i] = x.(Expression) }, nil)
+	}
+}
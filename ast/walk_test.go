@@ -0,0 +1,268 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parser errors %v", input, p.Errors())
+	}
+	return program
+}
+
+// describe names a node by its type and literal, like Identifier(x)
+func describe(node ast.Node) string {
+	name := reflect.TypeOf(node).Elem().Name()
+	switch node := node.(type) {
+	case *ast.Program, *ast.BlockStatement, *ast.LetStatement, *ast.ReturnStatement,
+		*ast.ExpressionStatement, *ast.ImportStatement, *ast.ExportStatement:
+		return name
+	case *ast.TypeAnnotation:
+		return name + "(" + node.Name + ")"
+	}
+	return name + "(" + node.TokenLiteral() + ")"
+}
+
+func TestInspect(t *testing.T) {
+	program := parse(t, `
+import "lib" as l;
+export let f = fn(a: int, b) -> bool { return -a < b; };
+while (x) { if (y) { h[1:2] } else { {"k": [l.v, 2.5]}[0] } };
+g(null, true, "s");`)
+
+	var visited []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			visited = append(visited, describe(node))
+		}
+		return true
+	})
+
+	expected := []string{
+		"Program",
+		"ImportStatement", "StringLiteral(lib)", "Identifier(l)",
+		"ExportStatement", "LetStatement", "Identifier(f)",
+		"FunctionLiteral(fn)", "Identifier(a)", "TypeAnnotation(int)", "Identifier(b)", "TypeAnnotation(bool)",
+		"BlockStatement", "ReturnStatement", "InfixExpression(<)", "PrefixExpression(-)", "Identifier(a)", "Identifier(b)",
+		"ExpressionStatement", "WhileExpression(while)", "Identifier(x)", "BlockStatement",
+		"ExpressionStatement", "IfExpression(if)", "Identifier(y)",
+		"BlockStatement", "ExpressionStatement", "SliceExpression([)", "Identifier(h)", "IntegerLiteral(1)", "IntegerLiteral(2)",
+		"BlockStatement", "ExpressionStatement", "IndexExpression([)", "HashLiteral({)", "StringLiteral(k)",
+		"ArrayLiteral([)", "MemberExpression(.)", "Identifier(l)", "Identifier(v)", "FloatLiteral(2.5)", "IntegerLiteral(0)",
+		"ExpressionStatement", "CallExpression(()", "Identifier(g)", "Null(null)", "Boolean(true)", "StringLiteral(s)",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("wrong nodes visited.\nexpected=%v\ngot=%v", expected, visited)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parse(t, "let f = fn(x) { y }; z;")
+
+	var idents []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			idents = append(idents, ident.Value)
+		}
+		_, isFunction := node.(*ast.FunctionLiteral)
+		return !isFunction
+	})
+
+	if expected := []string{"f", "z"}; !reflect.DeepEqual(idents, expected) {
+		t.Errorf("expected=%v, got=%v", expected, idents)
+	}
+}
+
+type depthVisitor struct {
+	depth int
+	out   *[]string
+}
+
+func (v depthVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*v.out = append(*v.out, fmt.Sprintf("end %d", v.depth))
+		return nil
+	}
+	*v.out = append(*v.out, fmt.Sprintf("%s %d", describe(node), v.depth))
+	return depthVisitor{depth: v.depth + 1, out: v.out}
+}
+
+func TestWalk(t *testing.T) {
+	var out []string
+	ast.Walk(depthVisitor{out: &out}, parse(t, "-x;"))
+
+	expected := []string{
+		"Program 0", "ExpressionStatement 1", "PrefixExpression(-) 2", "Identifier(x) 3",
+		"end 4", "end 3", "end 2", "end 1",
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected=%v\ngot=%v", expected, out)
+	}
+}
+
+func TestApply(t *testing.T) {
+	program := parse(t, `let a = 1; puts(2); let b = {3: 4}; fn(x) { 5 };`)
+
+	var names []string
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		switch node := c.Node().(type) {
+		case *ast.IntegerLiteral:
+			names = append(names, fmt.Sprintf("%s[%d]", c.Name(), c.Index()))
+			c.Replace(&ast.StringLiteral{Token: node.Token, Value: node.TokenLiteral()})
+		case *ast.ExpressionStatement:
+			if _, ok := node.Expression.(*ast.CallExpression); ok {
+				c.Delete()
+			}
+		}
+		return true
+	}, nil)
+
+	if got := program.String(); got != "let a = 1;let b = {3:4};fn(x) 5" {
+		t.Errorf("wrong program after Apply. got=%q", got)
+	}
+	expected := []string{"Value[-1]", "Arguments[0]", "Keys[0]", "Pairs[0]", "Expression[-1]"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("wrong cursor positions.\nexpected=%v\ngot=%v", expected, names)
+	}
+
+	hash := program.Statements[1].(*ast.LetStatement).Value.(*ast.HashLiteral)
+	key, ok := hash.Keys[0].(*ast.StringLiteral)
+	if !ok || hash.Pairs[key] == nil {
+		t.Errorf("hash key was not replaced in both Keys and Pairs")
+	}
+}
+
+func TestApplyStops(t *testing.T) {
+	program := parse(t, "1; 2; 3;")
+
+	var seen []string
+	ast.Apply(program, nil, func(c *ast.Cursor) bool {
+		if lit, ok := c.Node().(*ast.IntegerLiteral); ok {
+			seen = append(seen, lit.TokenLiteral())
+			return lit.Value != 2
+		}
+		return true
+	})
+
+	if expected := []string{"1", "2"}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("expected=%v, got=%v", expected, seen)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	program := parse(t, "let x = 1 + 2 * 3;")
+
+	// fold integer arithmetic from the leaves up
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		infix, ok := node.(*ast.InfixExpression)
+		if !ok {
+			return node
+		}
+		left, lok := infix.Left.(*ast.IntegerLiteral)
+		right, rok := infix.Right.(*ast.IntegerLiteral)
+		if !lok || !rok {
+			return node
+		}
+		value := left.Value + right.Value
+		if infix.Operator == "*" {
+			value = left.Value * right.Value
+		}
+		lit := &ast.IntegerLiteral{Token: left.Token, Value: value}
+		lit.Token.Literal = fmt.Sprint(value)
+		return lit
+	})
+
+	if got := program.String(); got != "let x = 7;" {
+		t.Errorf("wrong program after Rewrite. got=%q", got)
+	}
+
+	root := ast.Rewrite(parse(t, "x"), func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.Program); ok {
+			return &ast.Program{}
+		}
+		return node
+	})
+	if len(root.(*ast.Program).Statements) != 0 {
+		t.Errorf("the root was not replaced")
+	}
+}
+
+func TestApplyReplaceWrongType(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "ast.Expression") {
+			t.Errorf("expected a panic about the type, got %v", r)
+		}
+	}()
+
+	ast.Apply(parse(t, "let x = 1;"), func(c *ast.Cursor) bool {
+		if _, ok := c.Node().(*ast.IntegerLiteral); ok {
+			c.Replace(&ast.BlockStatement{})
+		}
+		return true
+	}, nil)
+}
+
+func TestCopy(t *testing.T) {
+	input := `let f = fn(a: int, b) -> int { if (a) { {b: [a, b[1:2]]} } else { m.x } }; macro(x) { x }`
+	program := parse(t, input)
+	copied := ast.Copy(program).(*ast.Program)
+
+	if copied == program || copied.String() != program.String() {
+		t.Fatalf("wrong copy. got=%q", copied.String())
+	}
+
+	// change every identifier in the copy, the original should be left alone
+	before := program.String()
+	ast.Inspect(copied, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			ident.Value += "2"
+		}
+		return true
+	})
+	ast.Apply(copied, func(c *ast.Cursor) bool {
+		if _, ok := c.Node().(*ast.IntegerLiteral); ok {
+			c.Replace(&ast.Null{})
+		}
+		return true
+	}, nil)
+	if got := program.String(); got != before {
+		t.Errorf("the original changed.\nexpected=%q\ngot=%q", before, got)
+	}
+	if copied.String() == before {
+		t.Errorf("the copy did not change")
+	}
+
+	if ast.Copy(nil) != nil {
+		t.Errorf("a nil node should copy to nil")
+	}
+}
+
+func TestQuoted(t *testing.T) {
+	program := parse(t, "quote(a + unquote(b)); quote(1, 2); unquote(c); q(d)")
+	calls := []ast.Node{}
+	for _, stmt := range program.Statements {
+		calls = append(calls, stmt.(*ast.ExpressionStatement).Expression)
+	}
+
+	if arg, ok := ast.Quoted(calls[0]); !ok || arg.String() != "(a + unquote(b))" {
+		t.Errorf("wrong quoted code %v %v", arg, ok)
+	}
+	if _, ok := ast.Quoted(calls[1]); ok {
+		t.Errorf("quote with two arguments should not be quoted code")
+	}
+	if arg, ok := ast.Unquoted(calls[2]); !ok || arg.String() != "c" {
+		t.Errorf("wrong unquoted code %v %v", arg, ok)
+	}
+	if _, ok := ast.Quoted(calls[3]); ok {
+		t.Errorf("a call to q should not be quoted code")
+	}
+}
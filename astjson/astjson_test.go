@@ -0,0 +1,184 @@
+package astjson
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/std"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parser errors %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestTokens(t *testing.T) {
+	tokens := Tokens("let s = \"a\\\"b\"; // note\nx->y")
+
+	expected := []struct {
+		typ     string
+		literal string
+		span    Span
+	}{
+		{"LET", "let", Span{Position{1, 1}, Position{1, 4}}},
+		{"IDENT", "s", Span{Position{1, 5}, Position{1, 6}}},
+		{"=", "=", Span{Position{1, 7}, Position{1, 8}}},
+		{"STRING", "a\"b", Span{Position{1, 9}, Position{1, 15}}},
+		{";", ";", Span{Position{1, 15}, Position{1, 16}}},
+		{"COMMENT", "// note", Span{Position{1, 17}, Position{1, 24}}},
+		{"IDENT", "x", Span{Position{2, 1}, Position{2, 2}}},
+		{"->", "->", Span{Position{2, 2}, Position{2, 4}}},
+		{"IDENT", "y", Span{Position{2, 4}, Position{2, 5}}},
+		{"EOF", "", Span{Position{2, 5}, Position{2, 6}}},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d: %v", len(expected), len(tokens), tokens)
+	}
+	for i, tt := range expected {
+		tok := tokens[i]
+		if tok.Type != tt.typ || tok.Literal != tt.literal || tok.Span != tt.span {
+			t.Errorf("tokens[%d] wrong. expected=%s %q %v, got=%s %q %v",
+				i, tt.typ, tt.literal, tt.span, tok.Type, tok.Literal, tok.Span)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	input := "let f = fn(a) {\n    a[1:] + g(2)\n};\n(1 + 2) * {\"k\": 3}[\"k\"];"
+	program := parse(t, input)
+	e := &encoder{tokenEnds: make(map[Position]Position), closes: make(map[Position]Position)}
+	e.scan(input)
+	spans := e.measure(program)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	sum := fn.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	stmt := program.Statements[1].(*ast.ExpressionStatement)
+	product := stmt.Expression.(*ast.InfixExpression)
+
+	tests := []struct {
+		node     ast.Node
+		expected Span
+	}{
+		{program, Span{Position{1, 1}, Position{4, 24}}},
+		{let, Span{Position{1, 1}, Position{3, 2}}},
+		{fn, Span{Position{1, 9}, Position{3, 2}}},
+		{fn.Body, Span{Position{1, 15}, Position{3, 2}}},
+		{sum, Span{Position{2, 5}, Position{2, 17}}},
+		{sum.Left, Span{Position{2, 5}, Position{2, 10}}},
+		{sum.Right, Span{Position{2, 13}, Position{2, 17}}},
+		{stmt, Span{Position{4, 1}, Position{4, 24}}},
+		{product.Left, Span{Position{4, 2}, Position{4, 7}}},
+		{product.Right, Span{Position{4, 11}, Position{4, 24}}},
+	}
+	for _, tt := range tests {
+		if got := spans[tt.node]; got != tt.expected {
+			t.Errorf("%s: wrong span. expected=%v, got=%v", tt.node, tt.expected, got)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		`import "std/math" as m;
+export let f = fn(a: int, b) -> bool { return -a < b; };
+let h = {"k": [m.pi, 2.5, null], 1: true};
+while (false) { if (h["k"][0:2:1]) { puts(h) } else { return null; } };`,
+		"let unless = macro(c, a) { quote(if (!unquote(c)) { unquote(a) }) }; unless(false, 1);",
+	}
+	for _, name := range std.Names() {
+		src, _ := std.Source(name)
+		inputs = append(inputs, src)
+	}
+
+	for _, input := range inputs {
+		program := parse(t, input)
+		data, err := Encode(program, input)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		decoded, err := Decode(data)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if decoded.String() != program.String() {
+			t.Errorf("program changed.\nexpected=%q\ngot=%q", program.String(), decoded.String())
+		}
+		again, _ := Encode(decoded, input)
+		if !bytes.Equal(again, data) {
+			t.Errorf("encoding the decoded program gave different JSON")
+		}
+	}
+}
+
+func TestDecodeWithoutTokens(t *testing.T) {
+	data := `{"node": "Program", "statements": [
+		{"node": "LetStatement", "name": {"node": "Identifier", "value": "double"},
+			"value": {"node": "FunctionLiteral", "parameters": [{"node": "Identifier", "value": "x"}],
+				"body": {"node": "BlockStatement", "statements": [{"node": "ExpressionStatement", "expression":
+					{"node": "InfixExpression", "operator": "*", "left": {"node": "Identifier", "value": "x"},
+						"right": {"node": "FloatLiteral", "value": 2}}}]}}},
+		{"node": "ExpressionStatement", "expression": {"node": "CallExpression",
+			"function": {"node": "Identifier", "value": "double"}, "arguments": [{"node": "IntegerLiteral", "value": 21}]}}
+	]}`
+
+	program, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got := program.String(); got != "let double = fn(x) (x * 2.0);double(21)" {
+		t.Errorf("wrong program. got=%q", got)
+	}
+
+	result := evaluator.Eval(program, object.NewModuleEnviroment(object.NewRuntime(), ""))
+	if float, ok := result.(*object.Float); !ok || float.Value != 42 {
+		t.Errorf("wrong result. expected 42.0, got %s", result.Inspect())
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[]`, "program: expected a node"},
+		{`{"node": "Identifier", "value": "x"}`, "program: expected a Program, got Identifier"},
+		{`{"statements": []}`, `program: missing "node"`},
+		{`{"node": "Program", "statements": [{"node": "Loop"}]}`, `program.statements[0]: unknown node "Loop"`},
+		{
+			`{"node": "Program", "statements": [{"node": "Identifier", "value": "x"}]}`,
+			"program.statements[0]: expected a statement, got Identifier",
+		},
+		{
+			`{"node": "Program", "statements": [{"node": "LetStatement", "name": {"node": "Identifier", "value": "x"}}]}`,
+			`program.statements[0]: missing "value"`,
+		},
+		{
+			`{"node": "Program", "statements": [{"node": "ReturnStatement", "returnValue": {"node": "BlockStatement"}}]}`,
+			"program.statements[0].returnValue: expected an expression, got BlockStatement",
+		},
+		{
+			`{"node": "Program", "statements": [{"node": "ExpressionStatement", "expression": {"node": "IntegerLiteral", "value": "1"}}]}`,
+			"program.statements[0].expression.value: expected int64",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode([]byte(tt.input))
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s: wrong error.\nexpected=%q\ngot=%q", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package astjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
+
+// Decode reads back a program dumped by Encode. Spans are ignored and a node
+// without a "token" gets the token the parser would have given it, with no
+// position, so tools can build nodes without knowing about tokens.
+func Decode(data []byte) (*ast.Program, error) {
+	d := &decoder{}
+	n := d.node(data, "program")
+	if d.err != nil {
+		return nil, d.err
+	}
+	program, ok := n.(*ast.Program)
+	if !ok {
+		return nil, fmt.Errorf("program: expected a Program, got %s", describe(n))
+	}
+	return program, nil
+}
+
+type decoder struct {
+	err error
+}
+
+func (d *decoder) fail(path, format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(path+": "+format, args...)
+	}
+}
+
+// node decodes the node at path, a path like program.statements[0].value
+// says where an error is
+func (d *decoder) node(data json.RawMessage, path string) ast.Node {
+	if d.err != nil || len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		d.fail(path, "expected a node")
+		return nil
+	}
+	var kind string
+	d.scalar(obj, path, "node", &kind)
+
+	switch kind {
+	case "Program":
+		return &ast.Program{Statements: d.statements(obj, path)}
+	case "BlockStatement":
+		return &ast.BlockStatement{Token: d.token(obj, path, token.LBRACE, "{"), Statements: d.statements(obj, path)}
+	case "LetStatement":
+		return d.let(obj, path)
+	case "ReturnStatement":
+		return &ast.ReturnStatement{
+			Token:       d.token(obj, path, token.RETURN, "return"),
+			ReturnValue: d.expression(obj, path, "returnValue", false),
+		}
+	case "ExpressionStatement":
+		exp := d.expression(obj, path, "expression", true)
+		stmt := &ast.ExpressionStatement{Expression: exp}
+		if exp != nil {
+			tok, _ := nodeToken(exp)
+			stmt.Token = d.token(obj, path, tok.Type, tok.Literal)
+		}
+		return stmt
+	case "ImportStatement":
+		n, alias := d.child(obj, path, "path", true), d.identifier(obj, path, "alias", true)
+		lit, ok := n.(*ast.StringLiteral)
+		if !ok && d.err == nil {
+			d.fail(path+".path", "expected a StringLiteral, got %s", describe(n))
+		}
+		return &ast.ImportStatement{Token: d.token(obj, path, token.IMPORT, "import"), Path: lit, Alias: alias}
+	case "ExportStatement":
+		n := d.child(obj, path, "statement", true)
+		stmt, ok := n.(*ast.LetStatement)
+		if !ok && d.err == nil {
+			d.fail(path+".statement", "expected a LetStatement, got %s", describe(n))
+		}
+		return &ast.ExportStatement{Token: d.token(obj, path, token.EXPORT, "export"), Statement: stmt}
+	case "Identifier":
+		var value string
+		d.scalar(obj, path, "value", &value)
+		return &ast.Identifier{Token: d.token(obj, path, token.IDENT, value), Value: value}
+	case "IntegerLiteral":
+		var value int64
+		d.scalar(obj, path, "value", &value)
+		return &ast.IntegerLiteral{Token: d.token(obj, path, token.INT, strconv.FormatInt(value, 10)), Value: value}
+	case "FloatLiteral":
+		var value float64
+		d.scalar(obj, path, "value", &value)
+		literal := strconv.FormatFloat(value, 'f', -1, 64)
+		if !strings.Contains(literal, ".") {
+			literal += ".0"
+		}
+		return &ast.FloatLiteral{Token: d.token(obj, path, token.FLOAT, literal), Value: value}
+	case "StringLiteral":
+		var value string
+		d.scalar(obj, path, "value", &value)
+		return &ast.StringLiteral{Token: d.token(obj, path, token.STRING, value), Value: value}
+	case "Boolean":
+		var value bool
+		d.scalar(obj, path, "value", &value)
+		if value {
+			return &ast.Boolean{Token: d.token(obj, path, token.TRUE, "true"), Value: value}
+		}
+		return &ast.Boolean{Token: d.token(obj, path, token.FALSE, "false"), Value: value}
+	case "Null":
+		return &ast.Null{Token: d.token(obj, path, token.NULL, "null")}
+	case "TypeAnnotation":
+		var name string
+		d.scalar(obj, path, "name", &name)
+		return &ast.TypeAnnotation{Token: d.token(obj, path, token.LookupIdent(name), name), Name: name}
+	case "FunctionLiteral":
+		return d.function(obj, path)
+	case "MacroLiteral":
+		return &ast.MacroLiteral{
+			Token:      d.token(obj, path, token.MACRO, "macro"),
+			Parameters: d.parameters(obj, path),
+			Body:       d.block(obj, path, "body", true),
+		}
+	case "ArrayLiteral":
+		return &ast.ArrayLiteral{Token: d.token(obj, path, token.LBRACKET, "["), Elements: d.expressions(obj, path, "elements")}
+	case "HashLiteral":
+		return d.hash(obj, path)
+	case "PrefixExpression":
+		var operator string
+		d.scalar(obj, path, "operator", &operator)
+		return &ast.PrefixExpression{
+			Token:    d.token(obj, path, token.Type(operator), operator),
+			Operator: operator,
+			Right:    d.expression(obj, path, "right", true),
+		}
+	case "InfixExpression":
+		var operator string
+		d.scalar(obj, path, "operator", &operator)
+		return &ast.InfixExpression{
+			Token:    d.token(obj, path, token.Type(operator), operator),
+			Left:     d.expression(obj, path, "left", true),
+			Operator: operator,
+			Right:    d.expression(obj, path, "right", true),
+		}
+	case "IfExpression":
+		return &ast.IfExpression{
+			Token:       d.token(obj, path, token.IF, "if"),
+			Condition:   d.expression(obj, path, "condition", true),
+			Consequence: d.block(obj, path, "consequence", true),
+			Alternative: d.block(obj, path, "alternative", false),
+		}
+	case "WhileExpression":
+		return &ast.WhileExpression{
+			Token: d.token(obj, path, token.WHILE, "while"),
+			Test:  d.expression(obj, path, "test", true),
+			Body:  d.block(obj, path, "body", true),
+		}
+	case "CallExpression":
+		return &ast.CallExpression{
+			Token:     d.token(obj, path, token.LPAREN, "("),
+			Function:  d.expression(obj, path, "function", true),
+			Arguments: d.expressions(obj, path, "arguments"),
+		}
+	case "IndexExpression":
+		return &ast.IndexExpression{
+			Token: d.token(obj, path, token.LBRACKET, "["),
+			Left:  d.expression(obj, path, "left", true),
+			Index: d.expression(obj, path, "index", true),
+		}
+	case "SliceExpression":
+		return &ast.SliceExpression{
+			Token: d.token(obj, path, token.LBRACKET, "["),
+			Left:  d.expression(obj, path, "left", true),
+			Start: d.expression(obj, path, "start", false),
+			End:   d.expression(obj, path, "end", false),
+			Step:  d.expression(obj, path, "step", false),
+		}
+	case "MemberExpression":
+		return &ast.MemberExpression{
+			Token:    d.token(obj, path, token.DOT, "."),
+			Object:   d.expression(obj, path, "object", true),
+			Property: d.identifier(obj, path, "property", true),
+		}
+	}
+	if d.err == nil {
+		d.fail(path, "unknown node %q", kind)
+	}
+	return nil
+}
+
+func (d *decoder) let(obj map[string]json.RawMessage, path string) *ast.LetStatement {
+	stmt := &ast.LetStatement{
+		Token: d.token(obj, path, token.LET, "let"),
+		Name:  d.identifier(obj, path, "name", true),
+		Value: d.expression(obj, path, "value", true),
+	}
+	if typ := d.child(obj, path, "type", false); typ != nil {
+		stmt.Type = d.annotation(typ, path+".type")
+	}
+	return stmt
+}
+
+func (d *decoder) function(obj map[string]json.RawMessage, path string) *ast.FunctionLiteral {
+	fn := &ast.FunctionLiteral{
+		Token:      d.token(obj, path, token.FUNCTION, "fn"),
+		Parameters: d.parameters(obj, path),
+		Body:       d.block(obj, path, "body", true),
+	}
+	for i, raw := range d.list(obj, path, "parameterTypes") {
+		at := fmt.Sprintf("%s.parameterTypes[%d]", path, i)
+		var typ *ast.TypeAnnotation
+		if n := d.node(raw, at); n != nil {
+			typ = d.annotation(n, at)
+		}
+		fn.ParameterTypes = append(fn.ParameterTypes, typ)
+	}
+	if typ := d.child(obj, path, "returnType", false); typ != nil {
+		fn.ReturnType = d.annotation(typ, path+".returnType")
+	}
+	return fn
+}
+
+func (d *decoder) parameters(obj map[string]json.RawMessage, path string) []*ast.Identifier {
+	var params []*ast.Identifier
+	for i, raw := range d.list(obj, path, "parameters") {
+		at := fmt.Sprintf("%s.parameters[%d]", path, i)
+		param, ok := d.node(raw, at).(*ast.Identifier)
+		if !ok && d.err == nil {
+			d.fail(at, "expected an Identifier")
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+func (d *decoder) hash(obj map[string]json.RawMessage, path string) *ast.HashLiteral {
+	hash := &ast.HashLiteral{Token: d.token(obj, path, token.LBRACE, "{"), Pairs: make(map[ast.Expression]ast.Expression)}
+	for i, raw := range d.list(obj, path, "pairs") {
+		at := fmt.Sprintf("%s.pairs[%d]", path, i)
+		var pair map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &pair); err != nil {
+			d.fail(at, "expected a pair")
+			return hash
+		}
+		key, value := d.expression(pair, at, "key", true), d.expression(pair, at, "value", true)
+		if d.err != nil {
+			return hash
+		}
+		hash.Keys = append(hash.Keys, key)
+		hash.Pairs[key] = value
+	}
+	return hash
+}
+
+// token decodes a node's token, or makes one with typ and literal when the
+// node doesn't have one
+func (d *decoder) token(obj map[string]json.RawMessage, path string, typ token.Type, literal string) token.Token {
+	raw, ok := obj["token"]
+	if !ok || string(raw) == "null" {
+		return token.Token{Type: typ, Literal: literal}
+	}
+
+	var tok Token
+	if err := json.Unmarshal(raw, &tok); err != nil {
+		d.fail(path+".token", "expected a token")
+	}
+	return token.Token{Type: token.Type(tok.Type), Literal: tok.Literal, Line: tok.Start.Line, Column: tok.Start.Column}
+}
+
+func (d *decoder) scalar(obj map[string]json.RawMessage, path, key string, v interface{}) {
+	raw, ok := obj[key]
+	if !ok {
+		d.fail(path, "missing %q", key)
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		d.fail(path+"."+key, "expected %s", strings.TrimPrefix(fmt.Sprintf("%T", v), "*"))
+	}
+}
+
+func (d *decoder) list(obj map[string]json.RawMessage, path, key string) []json.RawMessage {
+	var list []json.RawMessage
+	if raw, ok := obj[key]; ok && d.err == nil {
+		if err := json.Unmarshal(raw, &list); err != nil {
+			d.fail(path+"."+key, "expected a list")
+		}
+	}
+	return list
+}
+
+// child decodes the node in a field, a required one can't be missing or null
+func (d *decoder) child(obj map[string]json.RawMessage, path, key string, required bool) ast.Node {
+	n := d.node(obj[key], path+"."+key)
+	if n == nil && required {
+		d.fail(path, "missing %q", key)
+	}
+	return n
+}
+
+func (d *decoder) statements(obj map[string]json.RawMessage, path string) []ast.Statement {
+	stmts := []ast.Statement{}
+	for i, raw := range d.list(obj, path, "statements") {
+		at := fmt.Sprintf("%s.statements[%d]", path, i)
+		n := d.node(raw, at)
+		stmt, ok := n.(ast.Statement)
+		if !ok {
+			if d.err == nil {
+				d.fail(at, "expected a statement, got %s", describe(n))
+			}
+			continue
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func (d *decoder) expressions(obj map[string]json.RawMessage, path, key string) []ast.Expression {
+	exps := []ast.Expression{}
+	for i, raw := range d.list(obj, path, key) {
+		at := fmt.Sprintf("%s.%s[%d]", path, key, i)
+		n := d.node(raw, at)
+		exp, ok := n.(ast.Expression)
+		if !ok {
+			if d.err == nil {
+				d.fail(at, "expected an expression, got %s", describe(n))
+			}
+			continue
+		}
+		exps = append(exps, exp)
+	}
+	return exps
+}
+
+func (d *decoder) expression(obj map[string]json.RawMessage, path, key string, required bool) ast.Expression {
+	n := d.child(obj, path, key, required)
+	if n == nil {
+		return nil
+	}
+	exp, ok := n.(ast.Expression)
+	if !ok {
+		d.fail(path+"."+key, "expected an expression, got %s", describe(n))
+	}
+	return exp
+}
+
+func (d *decoder) identifier(obj map[string]json.RawMessage, path, key string, required bool) *ast.Identifier {
+	n := d.child(obj, path, key, required)
+	ident, ok := n.(*ast.Identifier)
+	if n != nil && !ok {
+		d.fail(path+"."+key, "expected an Identifier, got %s", describe(n))
+	}
+	return ident
+}
+
+func (d *decoder) block(obj map[string]json.RawMessage, path, key string, required bool) *ast.BlockStatement {
+	n := d.child(obj, path, key, required)
+	block, ok := n.(*ast.BlockStatement)
+	if n != nil && !ok {
+		d.fail(path+"."+key, "expected a BlockStatement, got %s", describe(n))
+	}
+	return block
+}
+
+func (d *decoder) annotation(n ast.Node, path string) *ast.TypeAnnotation {
+	typ, ok := n.(*ast.TypeAnnotation)
+	if !ok {
+		d.fail(path, "expected a TypeAnnotation, got %s", describe(n))
+	}
+	return typ
+}
+
+func describe(n ast.Node) string {
+	if n == nil {
+		return "nothing"
+	}
+	return fmt.Sprintf("%T", n)[len("*ast."):]
+}
@@ -0,0 +1,311 @@
+// Package astjson dumps tokens and syntax trees as JSON for tools written in
+// other languages, and reads trees back so they can be run.
+//
+// A node is an object whose "node" key names its type in the ast package,
+// like "InfixExpression", its other keys are the node's fields in lower camel
+// case. Positions count lines and byte columns from 1 and a span's end is
+// just after the node's last byte. Parentheses around an expression aren't
+// kept in the tree so they aren't part of its span.
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+	"reflect"
+	"sort"
+)
+
+// Position is a place in the source
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) before(other Position) bool {
+	return p.Line < other.Line || p.Line == other.Line && p.Column < other.Column
+}
+
+// Span is the source a token or node was read from
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+func (s Span) empty() bool {
+	return s.Start.Line == 0
+}
+
+// union is the smallest span covering both spans
+func (s Span) union(other Span) Span {
+	if s.empty() {
+		return other
+	}
+	if other.empty() {
+		return s
+	}
+	if other.Start.before(s.Start) {
+		s.Start = other.Start
+	}
+	if s.End.before(other.End) {
+		s.End = other.End
+	}
+	return s
+}
+
+// Token is a token with where it starts and ends
+type Token struct {
+	Type    string `json:"type"`
+	Literal string `json:"literal"`
+	Span
+}
+
+// Tokens lexes src, comments are included where they appear and the last
+// token is EOF
+func Tokens(src string) []Token {
+	var tokens []Token
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		line, column := l.Position()
+		tokens = append(tokens, Token{
+			Type:    string(tok.Type),
+			Literal: tok.Literal,
+			Span:    Span{Start: Position{tok.Line, tok.Column}, End: Position{line, column}},
+		})
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	for _, comment := range l.Comments() {
+		start := Position{comment.Line, comment.Column}
+		tokens = append(tokens, Token{
+			Type:    string(comment.Type),
+			Literal: comment.Literal,
+			Span:    Span{Start: start, End: Position{start.Line, start.Column + len(comment.Literal)}},
+		})
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].Start.before(tokens[j].Start)
+	})
+	return tokens
+}
+
+// Encode dumps a tree parsed from src as indented JSON
+func Encode(node ast.Node, src string) ([]byte, error) {
+	e := &encoder{tokenEnds: make(map[Position]Position), closes: make(map[Position]Position)}
+	e.scan(src)
+	e.spans = e.measure(node)
+	return json.MarshalIndent(e.node(node), "", "  ")
+}
+
+type encoder struct {
+	// tokenEnds maps the start of each token to its end
+	tokenEnds map[Position]Position
+	// closes maps the start of each (, [ and { to the end of its match
+	closes map[Position]Position
+	spans  map[ast.Node]Span
+}
+
+func (e *encoder) scan(src string) {
+	var open []Position
+	for _, tok := range Tokens(src) {
+		e.tokenEnds[tok.Start] = tok.End
+		switch token.Type(tok.Type) {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open = append(open, tok.Start)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(open) > 0 {
+				e.closes[open[len(open)-1]] = tok.End
+				open = open[:len(open)-1]
+			}
+		}
+	}
+}
+
+// measure finds the span of every node, a node covers its token, its
+// children and, when its token opens a bracket, the bracket's match
+func (e *encoder) measure(root ast.Node) map[ast.Node]Span {
+	spans := make(map[ast.Node]Span)
+	ast.Apply(root, nil, func(c *ast.Cursor) bool {
+		span := spans[c.Node()]
+		if tok, ok := nodeToken(c.Node()); ok && tok.Line > 0 {
+			start := Position{tok.Line, tok.Column}
+			span = span.union(Span{Start: start, End: e.tokenEnd(tok)})
+			if end, ok := e.closes[start]; ok {
+				span = span.union(Span{Start: start, End: end})
+			}
+		}
+		spans[c.Node()] = span
+		if c.Parent() != nil {
+			spans[c.Parent()] = spans[c.Parent()].union(span)
+		}
+		return true
+	})
+	return spans
+}
+
+func (e *encoder) tokenEnd(tok token.Token) Position {
+	start := Position{tok.Line, tok.Column}
+	if end, ok := e.tokenEnds[start]; ok {
+		return end
+	}
+	return Position{tok.Line, tok.Column + len(tok.Literal)}
+}
+
+func (e *encoder) token(tok token.Token) Token {
+	span := Span{Start: Position{tok.Line, tok.Column}}
+	if tok.Line > 0 {
+		span.End = e.tokenEnd(tok)
+	}
+	return Token{Type: string(tok.Type), Literal: tok.Literal, Span: span}
+}
+
+func (e *encoder) node(n ast.Node) interface{} {
+	if isNil(n) {
+		return nil
+	}
+
+	obj := ordered{{"node", reflect.TypeOf(n).Elem().Name()}}
+	if span := e.spans[n]; !span.empty() {
+		obj = append(obj, field{"span", span})
+	}
+	if tok, ok := nodeToken(n); ok {
+		obj = append(obj, field{"token", e.token(tok)})
+	}
+
+	switch n := n.(type) {
+	case *ast.Program:
+		obj = append(obj, field{"statements", e.statements(n.Statements)})
+	case *ast.BlockStatement:
+		obj = append(obj, field{"statements", e.statements(n.Statements)})
+	case *ast.LetStatement:
+		obj = append(obj, field{"name", e.node(n.Name)}, field{"type", e.node(n.Type)}, field{"value", e.node(n.Value)})
+	case *ast.ReturnStatement:
+		obj = append(obj, field{"returnValue", e.node(n.ReturnValue)})
+	case *ast.ExpressionStatement:
+		obj = append(obj, field{"expression", e.node(n.Expression)})
+	case *ast.ImportStatement:
+		obj = append(obj, field{"path", e.node(n.Path)}, field{"alias", e.node(n.Alias)})
+	case *ast.ExportStatement:
+		obj = append(obj, field{"statement", e.node(n.Statement)})
+	case *ast.Identifier:
+		obj = append(obj, field{"value", n.Value})
+	case *ast.IntegerLiteral:
+		obj = append(obj, field{"value", n.Value})
+	case *ast.FloatLiteral:
+		obj = append(obj, field{"value", n.Value})
+	case *ast.StringLiteral:
+		obj = append(obj, field{"value", n.Value})
+	case *ast.Boolean:
+		obj = append(obj, field{"value", n.Value})
+	case *ast.TypeAnnotation:
+		obj = append(obj, field{"name", n.Name})
+	case *ast.FunctionLiteral:
+		params := make([]interface{}, len(n.Parameters))
+		for i, param := range n.Parameters {
+			params[i] = e.node(param)
+		}
+		types := make([]interface{}, len(n.ParameterTypes))
+		for i, typ := range n.ParameterTypes {
+			types[i] = e.node(typ)
+		}
+		obj = append(obj, field{"parameters", params}, field{"parameterTypes", types},
+			field{"returnType", e.node(n.ReturnType)}, field{"body", e.node(n.Body)})
+	case *ast.MacroLiteral:
+		params := make([]interface{}, len(n.Parameters))
+		for i, param := range n.Parameters {
+			params[i] = e.node(param)
+		}
+		obj = append(obj, field{"parameters", params}, field{"body", e.node(n.Body)})
+	case *ast.ArrayLiteral:
+		obj = append(obj, field{"elements", e.expressions(n.Elements)})
+	case *ast.HashLiteral:
+		pairs := make([]interface{}, len(n.Keys))
+		for i, key := range n.Keys {
+			pairs[i] = ordered{{"key", e.node(key)}, {"value", e.node(n.Pairs[key])}}
+		}
+		obj = append(obj, field{"pairs", pairs})
+	case *ast.PrefixExpression:
+		obj = append(obj, field{"operator", n.Operator}, field{"right", e.node(n.Right)})
+	case *ast.InfixExpression:
+		obj = append(obj, field{"left", e.node(n.Left)}, field{"operator", n.Operator}, field{"right", e.node(n.Right)})
+	case *ast.IfExpression:
+		obj = append(obj, field{"condition", e.node(n.Condition)}, field{"consequence", e.node(n.Consequence)},
+			field{"alternative", e.node(n.Alternative)})
+	case *ast.WhileExpression:
+		obj = append(obj, field{"test", e.node(n.Test)}, field{"body", e.node(n.Body)})
+	case *ast.CallExpression:
+		obj = append(obj, field{"function", e.node(n.Function)}, field{"arguments", e.expressions(n.Arguments)})
+	case *ast.IndexExpression:
+		obj = append(obj, field{"left", e.node(n.Left)}, field{"index", e.node(n.Index)})
+	case *ast.SliceExpression:
+		obj = append(obj, field{"left", e.node(n.Left)}, field{"start", e.node(n.Start)},
+			field{"end", e.node(n.End)}, field{"step", e.node(n.Step)})
+	case *ast.MemberExpression:
+		obj = append(obj, field{"object", e.node(n.Object)}, field{"property", e.node(n.Property)})
+	}
+	// Null has nothing but its token
+	return obj
+}
+
+func (e *encoder) statements(stmts []ast.Statement) []interface{} {
+	out := make([]interface{}, len(stmts))
+	for i, stmt := range stmts {
+		out[i] = e.node(stmt)
+	}
+	return out
+}
+
+func (e *encoder) expressions(exps []ast.Expression) []interface{} {
+	out := make([]interface{}, len(exps))
+	for i, exp := range exps {
+		out[i] = e.node(exp)
+	}
+	return out
+}
+
+// ordered is a JSON object that keeps its keys in the order they were added
+type ordered []field
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+func (o ordered) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// nodeToken is a node's Token field, every node but Program has one
+func nodeToken(n ast.Node) (token.Token, bool) {
+	field := reflect.ValueOf(n).Elem().FieldByName("Token")
+	if !field.IsValid() {
+		return token.Token{}, false
+	}
+	return field.Interface().(token.Token), true
+}
+
+// isNil reports whether a node is missing, an unset field holds a nil pointer
+func isNil(n ast.Node) bool {
+	return n == nil || reflect.ValueOf(n).IsNil()
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"monkey/astjson"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/lsp"
+	"monkey/parser"
+	"monkey/typecheck"
+	"monkey/vet"
+	"os"
+	"path/filepath"
+)
+
+// commands are the tools run as `monkey <command> [arguments]`, anything
+// else is a script to run
+var commands = map[string]func(args []string) int{
+	"lsp":    lspCommand,
+	"fmt":    fmtCommand,
+	"vet":    vetCommand,
+	"check":  checkCommand,
+	"tokens": tokensCommand,
+	"ast":    astCommand,
+}
+
+// lspCommand serves the language server protocol over stdin and stdout
+func lspCommand(args []string) int {
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// fmtCommand formats files like gofmt, without files it formats stdin
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	diff := flags.Bool("d", false, "print diffs instead of the formatted source")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey fmt [-l] [-w] [-d] [path ...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err == nil {
+			err = formatFile("<stdin>", src, false, false, *diff)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	status := 0
+	walkSources(flags.Args(), func(file string, src []byte) {
+		if err := formatFile(file, src, *list, *write, *diff); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}, &status)
+	return status
+}
+
+// walkSources calls f with each file named in paths and each .mky file in
+// the directories named, errors reading them are printed and set status
+func walkSources(paths []string, f func(file string, src []byte), status *int) {
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (file != path && filepath.Ext(file) != ".mky") {
+				return nil
+			}
+
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				*status = 1
+				return nil
+			}
+			f(file, src)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			*status = 1
+		}
+	}
+}
+
+func formatFile(name string, src []byte, list, write, diff bool) error {
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("%s:%s", name, err)
+	}
+
+	changed := !bytes.Equal(src, formatted)
+	if list && changed {
+		fmt.Println(name)
+	}
+	if write && changed {
+		if err := ioutil.WriteFile(name, formatted, 0644); err != nil {
+			return err
+		}
+	}
+	if diff {
+		os.Stdout.Write(format.Diff(name, src, formatted))
+	}
+	if !list && !write && !diff {
+		os.Stdout.Write(formatted)
+	}
+	return nil
+}
+
+// vetCommand runs the checks in the vet package over files. Like go vet,
+// naming checks with -check runs only those and -check=false runs all but
+// those.
+func vetCommand(args []string) int {
+	flags := flag.NewFlagSet("vet", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the problems found as JSON")
+	enabled := make(map[string]*bool)
+	for _, check := range vet.Checks {
+		enabled[check.Name] = flags.Bool(check.Name, false, check.Doc)
+	}
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey vet [-json] [-check[=false] ...] path ...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	checks := vetChecks(flags, enabled)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	type problem struct {
+		File string `json:"file"`
+		vet.Diagnostic
+	}
+	problems := []problem{}
+	status := 0
+	walkSources(flags.Args(), func(file string, src []byte) {
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if errs := p.Diagnostics(); len(errs) != 0 {
+			for _, err := range errs {
+				fmt.Fprintf(os.Stderr, "%s:%s\n", file, err)
+			}
+			status = 1
+			return
+		}
+		for _, d := range vet.Run(program, checks, "ARGS") {
+			problems = append(problems, problem{File: file, Diagnostic: d})
+		}
+	}, &status)
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(problems, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		for _, p := range problems {
+			fmt.Printf("%s:%s\n", p.File, p.Diagnostic)
+		}
+	}
+	if len(problems) != 0 {
+		status = 1
+	}
+	return status
+}
+
+// vetChecks picks the checks to run from the flags that were set
+func vetChecks(flags *flag.FlagSet, enabled map[string]*bool) []*vet.Check {
+	only := false
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		if _, ok := enabled[f.Name]; ok {
+			set[f.Name] = true
+			only = only || *enabled[f.Name]
+		}
+	})
+
+	var checks []*vet.Check
+	for _, check := range vet.Checks {
+		if only && *enabled[check.Name] || !only && !set[check.Name] {
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// checkCommand type checks files without running them
+func checkCommand(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey check path ...")
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	walkSources(flags.Args(), func(file string, src []byte) {
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		errs := []error{}
+		for _, err := range p.Diagnostics() {
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			for _, err := range typecheck.Check(program).Errors {
+				errs = append(errs, err)
+			}
+		}
+
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", file, err)
+			status = 1
+		}
+	}, &status)
+	return status
+}
+
+// readInput reads the file named by the first argument, or stdin without
+// one, and returns the name to use for it in messages
+func readInput(args []string) (string, []byte, error) {
+	if len(args) == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		return "<stdin>", src, err
+	}
+	src, err := ioutil.ReadFile(args[0])
+	return args[0], src, err
+}
+
+// tokensCommand prints the tokens of a file, or of stdin, as JSON
+func tokensCommand(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey tokens [path]")
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	_, src, err := readInput(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, _ := json.MarshalIndent(astjson.Tokens(string(src)), "", "  ")
+	fmt.Println(string(out))
+	return 0
+}
+
+// astCommand prints the syntax tree of a file, or of stdin, as JSON. With
+// -run it reads a tree in that form instead and runs it, the arguments after
+// the file are the script's ARGS.
+func astCommand(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	run := flags.Bool("run", false, "run a tree read as JSON instead of printing one")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey ast [path]\n       monkey ast -run [path [args ...]]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if !*run && flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file, src, err := readInput(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *run {
+		program, err := astjson.Decode(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			return 1
+		}
+		runtime, err := newRuntime()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		var rest []string
+		if flags.NArg() > 1 {
+			rest = flags.Args()[1:]
+		}
+		runtime.Globals["ARGS"] = scriptArgs(rest)
+		if flags.NArg() == 0 {
+			file = ""
+		}
+		return runProgram(file, program, runtime)
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.Diagnostics(); len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", file, err)
+		}
+		return 1
+	}
+	out, err := astjson.Encode(program, string(src))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(string(out))
+	return 0
+}
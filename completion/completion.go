@@ -0,0 +1,205 @@
+// Package completion works out what can be typed next at the end of some
+// monkey source, for the repl's tab completion and the language server
+package completion
+
+import (
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// Kind is what a candidate names
+type Kind int
+
+const (
+	Variable Kind = iota
+	Builtin
+	Constant
+	Keyword
+	// Key is a string key of a hash, completed after `h["`
+	Key
+	// Member is an export of a module or a key of a hash, completed after `m.`
+	Member
+)
+
+var kindNames = map[Kind]string{
+	Variable: "variable",
+	Builtin:  "builtin",
+	Constant: "constant",
+	Keyword:  "keyword",
+	Key:      "key",
+	Member:   "member",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Candidate is one possible completion
+type Candidate struct {
+	// Text replaces the input from the result's Start
+	Text string
+	Kind Kind
+}
+
+// Result holds the completions for the end of an input
+type Result struct {
+	// Start is the offset in the input where the word being completed begins
+	Start      int
+	Candidates []Candidate
+}
+
+// Complete completes the word at the end of input. Names come from env and
+// its outer enviroments, the runtime's globals, the builtins and the
+// keywords; env may be nil when there are no bindings to offer.
+func Complete(input string, env *object.Enviroment) Result {
+	if result, ok := completeKey(input, env); ok {
+		return result
+	}
+
+	start := wordStart(input)
+	prefix := input[start:]
+	if start > 0 && input[start-1] == '.' {
+		return completeMember(input[:start-1], prefix, start, env)
+	}
+	if prefix == "" || isDigit(prefix[0]) {
+		return Result{Start: start}
+	}
+
+	var c collector
+	c.init(prefix)
+	for e := env; e != nil; e = e.Outer() {
+		for _, name := range e.Names() {
+			c.add(name, Variable)
+		}
+	}
+	if env != nil {
+		for name := range env.Runtime().Globals {
+			c.add(name, Variable)
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		c.add(name, Builtin)
+	}
+	for _, name := range evaluator.ConstantNames() {
+		c.add(name, Constant)
+	}
+	for _, word := range token.Keywords() {
+		c.add(word, Keyword)
+	}
+
+	return Result{Start: start, Candidates: c.sorted()}
+}
+
+// completeKey offers the string keys of a hash when the input ends inside
+// `name["`, the candidates close the string and the brackets
+func completeKey(input string, env *object.Enviroment) (Result, bool) {
+	quote := strings.LastIndex(input, `["`)
+	if quote < 0 || strings.ContainsAny(input[quote+2:], `"\`) {
+		return Result{}, false
+	}
+
+	nameStart := wordStart(input[:quote])
+	name := input[nameStart:quote]
+	if name == "" || env == nil {
+		return Result{}, false
+	}
+
+	start := quote + 2
+	hash, ok := lookup(env, name).(*object.Hash)
+	if !ok {
+		return Result{Start: start}, true
+	}
+
+	var c collector
+	c.init(input[start:])
+	for _, pair := range hash.Ordered() {
+		if key, ok := pair.Key.(*object.String); ok {
+			c.add(key.Value+`"]`, Key)
+		}
+	}
+	return Result{Start: start, Candidates: c.sorted()}, true
+}
+
+// completeMember offers the exports of a module or the string keys of a hash
+// that can follow the dot
+func completeMember(before, prefix string, start int, env *object.Enviroment) Result {
+	name := before[wordStart(before):]
+	if name == "" || env == nil {
+		return Result{Start: start}
+	}
+
+	var c collector
+	c.init(prefix)
+	switch value := lookup(env, name).(type) {
+	case *object.Module:
+		for name := range value.Exports {
+			c.add(name, Member)
+		}
+	case *object.Hash:
+		for _, pair := range value.Ordered() {
+			if key, ok := pair.Key.(*object.String); ok && isIdentifier(key.Value) {
+				c.add(key.Value, Member)
+			}
+		}
+	}
+	return Result{Start: start, Candidates: c.sorted()}
+}
+
+func lookup(env *object.Enviroment, name string) object.Object {
+	if value, ok := env.Get(name); ok {
+		return value
+	}
+	return env.Runtime().Globals[name]
+}
+
+// collector gathers the candidates matching a prefix, the first kind added
+// for a name wins so bindings hide the builtins they shadow
+type collector struct {
+	prefix string
+	seen   map[string]bool
+	found  []Candidate
+}
+
+func (c *collector) init(prefix string) {
+	c.prefix = prefix
+	c.seen = make(map[string]bool)
+}
+
+func (c *collector) add(text string, kind Kind) {
+	if c.seen[text] || !strings.HasPrefix(text, c.prefix) {
+		return
+	}
+	c.seen[text] = true
+	c.found = append(c.found, Candidate{Text: text, Kind: kind})
+}
+
+func (c *collector) sorted() []Candidate {
+	sort.Slice(c.found, func(i, j int) bool {
+		return c.found[i].Text < c.found[j].Text
+	})
+	return c.found
+}
+
+// wordStart finds where the identifier characters at the end of s begin
+func wordStart(s string) int {
+	i := len(s)
+	for i > 0 && isIdentChar(s[i-1]) {
+		i--
+	}
+	return i
+}
+
+func isIdentifier(s string) bool {
+	return s != "" && !isDigit(s[0]) && wordStart(s) == 0
+}
+
+func isIdentChar(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || isDigit(ch)
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
@@ -0,0 +1,79 @@
+package completion
+
+import (
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func testEnv(t *testing.T, input string) *object.Enviroment {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	env := object.NewEnviroment()
+	if result := evaluator.Eval(program, env); result != nil && result.Type() == object.ErrorObj {
+		t.Fatalf("eval failed: %s", result.Inspect())
+	}
+	return env
+}
+
+func texts(result Result) string {
+	var out []string
+	for _, candidate := range result.Candidates {
+		out = append(out, candidate.Text+":"+candidate.Kind.String())
+	}
+	return strings.Join(out, " ")
+}
+
+func TestComplete(t *testing.T) {
+	env := testEnv(t, `
+		let counter = 1;
+		let count_all = fn() {};
+		let len = 2;
+		let config = {"name": "x", "name_long": 1, "two words": 2, 3: 4};
+		import "std/math" as m;`)
+	env.Runtime().Globals["CONFIG_ARGS"] = &object.Array{}
+	inner := object.NewEnclosedEnviroment(env)
+	inner.Set("cool", &object.Integer{Value: 1})
+
+	tests := []struct {
+		input    string
+		env      *object.Enviroment
+		start    int
+		expected string
+	}{
+		{"puts(coun", env, 5, "count_all:variable counter:variable"},
+		{"co", inner, 0, "config:variable contains:builtin cool:variable cos:builtin count_all:variable counter:variable"},
+		{"le", env, 0, "len:variable let:keyword"},
+		{"PI", env, 0, "PI:constant"},
+		{"CONF", env, 0, "CONFIG_ARGS:variable"},
+		{"wh", nil, 0, "while:keyword"},
+		{"le", nil, 0, "len:builtin let:keyword"},
+		{`config["na`, env, 8, `name"]:key name_long"]:key`},
+		{`config["`, env, 8, `name"]:key name_long"]:key two words"]:key`},
+		{`nope["na`, env, 6, ""},
+		{`config["name"] + co`, env, 17, "config:variable contains:builtin cos:builtin count_all:variable counter:variable"},
+		{"m.is_", env, 2, "is_even:member is_odd:member is_prime:member"},
+		{"config.n", env, 7, "name:member name_long:member"},
+		{"1 + 2", env, 4, ""},
+		{"1.", env, 2, ""},
+		{"", env, 0, ""},
+		{"x + ", env, 4, ""},
+	}
+
+	for _, tt := range tests {
+		result := Complete(tt.input, tt.env)
+		if result.Start != tt.start {
+			t.Errorf("wrong start for %q. expected=%d, got=%d", tt.input, tt.start, result.Start)
+		}
+		if texts(result) != tt.expected {
+			t.Errorf("wrong candidates for %q. expected=%q, got=%q", tt.input, tt.expected, texts(result))
+		}
+	}
+}
@@ -4,14 +4,63 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"math/rand"
+	"math"
 	"monkey/object"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
+	"unicode/utf8"
 )
 
+// BuiltinNames returns the names of the builtin functions, sorted
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Builtin looks up a builtin function by name
+func Builtin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// Constant looks up a predefined constant by name
+func Constant(name string) (object.Object, bool) {
+	constant, ok := constants[name]
+	return constant, ok
+}
+
+// Predeclared reports whether a name is found without being bound by the
+// program: quote, unquote, a global of the runtime, a builtin or a constant
+func Predeclared(runtime *object.Runtime, name string) bool {
+	if name == "quote" || name == "unquote" {
+		return true
+	}
+	if _, ok := runtime.Globals[name]; ok {
+		return true
+	}
+	if _, ok := builtins[name]; ok {
+		return true
+	}
+	_, ok := constants[name]
+	return ok
+}
+
+// ConstantNames returns the names of the predefined constants, sorted
+func ConstantNames() []string {
+	names := make([]string, 0, len(constants))
+	for name := range constants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 var builtins = map[string]*object.Builtin{
 	"len":     &object.Builtin{Fn: lenBuiltin},
 	"first":   &object.Builtin{Fn: firstBuiltin},
@@ -25,24 +74,93 @@ var builtins = map[string]*object.Builtin{
 	"gets":    &object.Builtin{Fn: getsBuiltin},
 	"geti":    &object.Builtin{Fn: getiBuiltin},
 	"random":  &object.Builtin{Fn: randomBuiltin},
+	"type":    &object.Builtin{Fn: typeBuiltin},
+	"error":   &object.Builtin{Fn: errorBuiltin},
+	"keys":    &object.Builtin{Fn: keysBuiltin},
+	"values":  &object.Builtin{Fn: valuesBuiltin},
+	"items":   &object.Builtin{Fn: itemsBuiltin},
+	"has":     &object.Builtin{Fn: hasBuiltin},
+	"get":     &object.Builtin{Fn: getBuiltin},
+	"delete":  &object.Builtin{Fn: deleteBuiltin},
+	"merge":   &object.Builtin{Fn: mergeBuiltin},
+
+	"split":       &object.Builtin{Fn: splitBuiltin},
+	"join":        &object.Builtin{Fn: joinBuiltin},
+	"trim":        &object.Builtin{Fn: trimBuiltin},
+	"upper":       &object.Builtin{Fn: upperBuiltin},
+	"lower":       &object.Builtin{Fn: lowerBuiltin},
+	"contains":    &object.Builtin{Fn: containsBuiltin},
+	"starts_with": &object.Builtin{Fn: startsWithBuiltin},
+	"ends_with":   &object.Builtin{Fn: endsWithBuiltin},
+	"index_of":    &object.Builtin{Fn: indexOfBuiltin},
+	"replace_all": &object.Builtin{Fn: replaceAllBuiltin},
+	"repeat":      &object.Builtin{Fn: repeatBuiltin},
+	"chars":       &object.Builtin{Fn: charsBuiltin},
+	"substr":      &object.Builtin{Fn: substrBuiltin},
+	"format":      &object.Builtin{Fn: formatBuiltin},
+
+	"seed":         &object.Builtin{Fn: seedBuiltin},
+	"random_range": &object.Builtin{Fn: randomRangeBuiltin},
+	"choice":       &object.Builtin{Fn: choiceBuiltin},
+	"shuffle":      &object.Builtin{Fn: shuffleBuiltin},
+
+	"env_get": &object.Builtin{Fn: envGetBuiltin},
+	"env_set": &object.Builtin{Fn: envSetBuiltin},
+	"exit":    &object.Builtin{Fn: exitBuiltin},
+
+	"json_encode": &object.Builtin{Fn: jsonEncodeBuiltin},
+	"json_decode": &object.Builtin{Fn: jsonDecodeBuiltin},
+
+	"read_file":   &object.Builtin{Fn: readFileBuiltin},
+	"write_file":  &object.Builtin{Fn: writeFileBuiltin},
+	"append_file": &object.Builtin{Fn: appendFileBuiltin},
+	"exists":      &object.Builtin{Fn: existsBuiltin},
+	"list_dir":    &object.Builtin{Fn: listDirBuiltin},
+	"mkdir":       &object.Builtin{Fn: mkdirBuiltin},
+	"remove":      &object.Builtin{Fn: removeBuiltin},
+
+	"abs":   &object.Builtin{Fn: absBuiltin},
+	"min":   &object.Builtin{Fn: minBuiltin},
+	"max":   &object.Builtin{Fn: maxBuiltin},
+	"pow":   &object.Builtin{Fn: powBuiltin},
+	"sqrt":  unaryFloatBuiltin("sqrt", math.Sqrt, nonNegative),
+	"floor": roundingBuiltin("floor", math.Floor),
+	"ceil":  roundingBuiltin("ceil", math.Ceil),
+	"round": roundingBuiltin("round", math.Round),
+	"clamp": &object.Builtin{Fn: clampBuiltin},
+	"gcd":   &object.Builtin{Fn: gcdBuiltin},
+	"lcm":   &object.Builtin{Fn: lcmBuiltin},
+	"sin":   unaryFloatBuiltin("sin", math.Sin, nil),
+	"cos":   unaryFloatBuiltin("cos", math.Cos, nil),
+	"tan":   unaryFloatBuiltin("tan", math.Tan, nil),
+	"asin":  unaryFloatBuiltin("asin", math.Asin, unitRange),
+	"acos":  unaryFloatBuiltin("acos", math.Acos, unitRange),
+	"atan":  unaryFloatBuiltin("atan", math.Atan, nil),
+	"atan2": &object.Builtin{Fn: atan2Builtin},
+	"log":   &object.Builtin{Fn: logBuiltin},
+	"exp":   unaryFloatBuiltin("exp", math.Exp, nil),
+	"int":   &object.Builtin{Fn: intBuiltin},
+	"float": &object.Builtin{Fn: floatBuiltin},
 }
 
-func lenBuiltin(args ...object.Object) object.Object {
+func lenBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return newError("wrong number of arguments. got=%d, want=1", len(args))
 	}
 
 	switch arg := args[0].(type) {
 	case *object.String:
-		return &object.Integer{Value: int64(len(arg.Value))}
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 	case *object.Array:
 		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(len(arg.Keys))}
 	default:
 		return newError("argument to `len` not supported, got %s", arg.Type())
 	}
 }
 
-func firstBuiltin(args ...object.Object) object.Object {
+func firstBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return newError("wrong number of arguments. got=%d, want=1", len(args))
 	}
@@ -59,7 +177,7 @@ func firstBuiltin(args ...object.Object) object.Object {
 	return nullObj
 }
 
-func lastBuiltin(args ...object.Object) object.Object {
+func lastBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return newError("wrong number of arguments. got=%d, want=1", len(args))
 	}
@@ -77,7 +195,7 @@ func lastBuiltin(args ...object.Object) object.Object {
 	return nullObj
 }
 
-func restBuiltin(args ...object.Object) object.Object {
+func restBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return newError("wrong number of arguments. got=%d, want=1", len(args))
 	}
@@ -97,7 +215,7 @@ func restBuiltin(args ...object.Object) object.Object {
 
 }
 
-func pushBuiltin(args ...object.Object) object.Object {
+func pushBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) != 2 {
 		return newError("wrong number of arguments. got=%d, want=2",
 			len(args))
@@ -113,7 +231,7 @@ func pushBuiltin(args ...object.Object) object.Object {
 	return arr
 }
 
-func popBuiltin(args ...object.Object) object.Object {
+func popBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return newError("wrong number of arguments. got=%d, want=1",
 			len(args))
@@ -140,7 +258,7 @@ func popBuiltin(args ...object.Object) object.Object {
 	return output
 }
 
-func replaceBuiltin(args ...object.Object) object.Object {
+func replaceBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) != 3 {
 		return newError("wrong number of arguments. got=%d, want=3",
 			len(args))
@@ -149,8 +267,10 @@ func replaceBuiltin(args ...object.Object) object.Object {
 	switch args[0].(type) {
 	case *object.Array:
 		return replaceArray(args...)
+	case *object.Hash:
+		return replaceHash(args...)
 	default:
-		return newError("argument to `push` must be ARRAY or HASH, got %s", args[0].Type())
+		return newError("argument to `replace` must be ARRAY or HASH, got %s", args[0].Type())
 	}
 }
 
@@ -173,7 +293,19 @@ func replaceArray(args ...object.Object) object.Object {
 
 }
 
-func boolBuiltin(args ...object.Object) object.Object {
+func replaceHash(args ...object.Object) object.Object {
+	hash := args[0].(*object.Hash)
+	key, ok := args[1].(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+
+	hash.Set(key.HashKey(), object.HashPair{Key: args[1], Value: args[2]})
+
+	return hash
+}
+
+func boolBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return newError("wrong number of arguments. got=%d, want=1",
 			len(args))
@@ -184,7 +316,7 @@ func boolBuiltin(args ...object.Object) object.Object {
 	return falseObj
 }
 
-func putsBuiltin(args ...object.Object) object.Object {
+func putsBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	var out bytes.Buffer
 	for _, arg := range args {
 		out.WriteString(arg.Inspect())
@@ -194,7 +326,7 @@ func putsBuiltin(args ...object.Object) object.Object {
 	return nullObj
 }
 
-func getsBuiltin(args ...object.Object) object.Object {
+func getsBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) == 1 {
 		fmt.Printf(args[0].Inspect())
 	}
@@ -205,7 +337,7 @@ func getsBuiltin(args ...object.Object) object.Object {
 	return &object.String{Value: text}
 }
 
-func getiBuiltin(args ...object.Object) object.Object {
+func getiBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
 	if len(args) == 1 {
 		fmt.Printf(args[0].Inspect())
 	}
@@ -223,9 +355,16 @@ func getiBuiltin(args ...object.Object) object.Object {
 	return &object.Integer{Value: int64(value)}
 }
 
-func randomBuiltin(args ...object.Object) object.Object {
+// random(n) returns an integer from 0 up to n, without an argument it
+// returns a float from 0 up to 1
+func randomBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	rng := env.Runtime().Rand
+
+	if len(args) == 0 {
+		return &object.Float{Value: rng.Float64()}
+	}
 	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1", len(args))
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
 	}
 	if args[0].Type() != object.IntegerObj {
 		return newError("argument to `random` must be INTEGER, got %s", args[0].Type())
@@ -234,11 +373,251 @@ func randomBuiltin(args ...object.Object) object.Object {
 	cap := args[0].(*object.Integer).Value
 
 	if cap < 1 {
-		return newError("cap value must be at least 1, got %s", args[0].Type())
+		return newError("cap value must be at least 1, got %d", cap)
 	}
 
-	rand.Seed(time.Now().UnixNano())
-	value := rand.Intn(int(cap))
+	value := rng.Int63n(cap)
 
-	return &object.Integer{Value: int64(value)}
+	return &object.Integer{Value: value}
+}
+
+func seedBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if args[0].Type() != object.IntegerObj {
+		return newError("argument to `seed` must be INTEGER, got %s", args[0].Type())
+	}
+
+	env.Runtime().Seed(args[0].(*object.Integer).Value)
+
+	return nullObj
+}
+
+// random_range(lo, hi) returns a number from lo up to, but not including,
+// hi, it is a float when either bound is a float
+func randomRangeBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	rng := env.Runtime().Rand
+
+	lo, loOk := args[0].(*object.Integer)
+	hi, hiOk := args[1].(*object.Integer)
+	if loOk && hiOk {
+		if lo.Value >= hi.Value {
+			return newError("random_range needs lo < hi, got %d and %d", lo.Value, hi.Value)
+		}
+		// the span is exact as a uint64 even when hi - lo overflows an int64
+		span := uint64(hi.Value) - uint64(lo.Value)
+		if span <= math.MaxInt64 {
+			return &object.Integer{Value: lo.Value + rng.Int63n(int64(span))}
+		}
+		for {
+			// more than half of all draws are below span
+			if n := rng.Uint64(); n < span {
+				return &object.Integer{Value: lo.Value + int64(n)}
+			}
+		}
+	}
+
+	values, err := numberArgs("random_range", args)
+	if err != nil {
+		return err
+	}
+	if values[0] >= values[1] {
+		return newError("random_range needs lo < hi, got %s and %s", args[0].Inspect(), args[1].Inspect())
+	}
+
+	return &object.Float{Value: values[0] + rng.Float64()*(values[1]-values[0])}
+}
+
+func choiceBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	elements, err := arrayArgument("choice", args[0])
+	if err != nil {
+		return err
+	}
+	if len(elements) == 0 {
+		return newError("cannot choose from an empty %s", args[0].Type())
+	}
+
+	return elements[env.Runtime().Rand.Intn(len(elements))]
+}
+
+// shuffle reorders an array in place, like push and pop it changes its argument
+func shuffleBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if args[0].Type() != object.ArrayObj {
+		return newError("argument to `shuffle` must be ARRAY, got %s", args[0].Type())
+	}
+
+	arr := args[0].(*object.Array)
+	env.Runtime().Rand.Shuffle(len(arr.Elements), func(i, j int) {
+		arr.Elements[i], arr.Elements[j] = arr.Elements[j], arr.Elements[i]
+	})
+
+	return arr
+}
+
+func typeBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	return &object.String{Value: string(args[0].Type())}
+}
+
+// error raises an error with the given message, stopping evaluation like any other error
+func errorBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	return newError("%s", args[0].Inspect())
+}
+
+// The hash builtins follow the array ones: `replace` and `delete` change the
+// hash in place like `push` and `pop`, everything else returns a new value
+// and leaves its arguments untouched like `rest`.
+
+func keysBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if args[0].Type() != object.HashObj {
+		return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+	}
+
+	hash := args[0].(*object.Hash)
+	elements := make([]object.Object, 0, len(hash.Keys))
+	for _, pair := range hash.Ordered() {
+		elements = append(elements, pair.Key)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func valuesBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if args[0].Type() != object.HashObj {
+		return newError("argument to `values` must be HASH, got %s", args[0].Type())
+	}
+
+	hash := args[0].(*object.Hash)
+	elements := make([]object.Object, 0, len(hash.Keys))
+	for _, pair := range hash.Ordered() {
+		elements = append(elements, pair.Value)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func itemsBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if args[0].Type() != object.HashObj {
+		return newError("argument to `items` must be HASH, got %s", args[0].Type())
+	}
+
+	hash := args[0].(*object.Hash)
+	elements := make([]object.Object, 0, len(hash.Keys))
+	for _, pair := range hash.Ordered() {
+		item := &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+		elements = append(elements, item)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func hasBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	if args[0].Type() != object.HashObj {
+		return newError("argument to `has` must be HASH, got %s", args[0].Type())
+	}
+
+	key, ok := args[1].(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+
+	_, ok = args[0].(*object.Hash).Get(key.HashKey())
+	return nativeBoolToBoolObject(ok)
+}
+
+func getBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+	if args[0].Type() != object.HashObj {
+		return newError("argument to `get` must be HASH, got %s", args[0].Type())
+	}
+
+	key, ok := args[1].(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+
+	pair, ok := args[0].(*object.Hash).Get(key.HashKey())
+	if ok {
+		return pair.Value
+	}
+	if len(args) == 3 {
+		return args[2]
+	}
+
+	return nullObj
+}
+
+func deleteBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	if args[0].Type() != object.HashObj {
+		return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+	}
+
+	key, ok := args[1].(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+
+	hash := args[0].(*object.Hash)
+	pair, ok := hash.Get(key.HashKey())
+	if !ok {
+		return nullObj
+	}
+
+	hash.Delete(key.HashKey())
+
+	return pair.Value
+}
+
+func mergeBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+	}
+
+	merged := object.NewHash()
+	for _, arg := range args {
+		hash, ok := arg.(*object.Hash)
+		if !ok {
+			return newError("argument to `merge` must be HASH, got %s", arg.Type())
+		}
+
+		for _, key := range hash.Keys {
+			merged.Set(key, hash.Pairs[key])
+		}
+	}
+
+	return merged
 }
@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/object"
 )
@@ -12,6 +13,10 @@ var (
 	nullObj  = &object.Null{}
 )
 
+// maxCallDepth is the deepest non-tail recursion allowed before evaluation
+// is stopped with an error, well before the go stack would overflow
+const maxCallDepth = 10000
+
 //Eval evaluates a node and returns an object
 func Eval(node ast.Node, env *object.Enviroment) object.Object {
 	switch node := node.(type) {
@@ -24,6 +29,9 @@ func Eval(node ast.Node, env *object.Enviroment) object.Object {
 	case *ast.BlockStatement:
 		return evalBlockStatement(node.Statements, env)
 	case *ast.ReturnStatement:
+		if call, ok := node.ReturnValue.(*ast.CallExpression); ok && !isQuoteCall(call) {
+			return evalTailCall(call, env)
+		}
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
@@ -35,10 +43,16 @@ func Eval(node ast.Node, env *object.Enviroment) object.Object {
 			return val
 		}
 		env.Set(node.Name.Value, val)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return evalExportStatement(node, env)
 
 	//Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.Boolean:
@@ -80,7 +94,12 @@ func Eval(node ast.Node, env *object.Enviroment) object.Object {
 		params := node.Parameters
 		body := node.Body
 		return &object.Function{Parameters: params, Env: env, Body: body}
+	case *ast.MacroLiteral:
+		return newError("macro literals are only allowed in a let at the top level")
 	case *ast.CallExpression:
+		if isQuoteCall(node) {
+			return evalQuoteCall(node, env)
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -91,7 +110,7 @@ func Eval(node ast.Node, env *object.Enviroment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(function, args, env)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -102,6 +121,10 @@ func Eval(node ast.Node, env *object.Enviroment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
 	}
 	return nil
 }
@@ -118,6 +141,8 @@ func isTruthy(obj object.Object) bool {
 		switch {
 		case obj.Type() == object.IntegerObj:
 			return obj.(*object.Integer).Value != 0
+		case obj.Type() == object.FloatObj:
+			return obj.(*object.Float).Value != 0
 		case obj.Type() == object.StringObj:
 			return obj.(*object.String).Value != ""
 		case obj.Type() == object.ArrayObj:
@@ -148,6 +173,8 @@ func evalProgram(statements []ast.Statement, env *object.Enviroment) object.Obje
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
+		case *object.TailCall:
+			return applyFunction(result.Function, result.Arguments, env)
 		case *object.Error:
 			return result
 		}
@@ -163,7 +190,7 @@ func evalBlockStatement(statements []ast.Statement, env *object.Enviroment) obje
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
-			if rt == object.ReturnValueObj || rt == object.ErrorObj {
+			if rt == object.ReturnValueObj || rt == object.ErrorObj || rt == object.TailCallObj {
 				return result
 			}
 		}
@@ -204,17 +231,22 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.IntegerObj {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalInfIxExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.IntegerObj && right.Type() == object.IntegerObj:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.StringObj && right.Type() == object.StringObj:
 		return evalStringInfixExpression(operator, left, right)
 	case left.Type() != right.Type():
@@ -240,8 +272,14 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal % rightVal}
 	case "<":
 		return nativeBoolToBoolObject(leftVal < rightVal)
@@ -256,6 +294,41 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+// evalFloatInfixExpression handles floats, and integers mixed with floats
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal, _ := toFloat(left)
+	rightVal, _ := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBoolObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBoolObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBoolObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBoolObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -263,6 +336,10 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	switch operator {
 	case "+":
 		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBoolObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBoolObject(leftVal > rightVal)
 	case "==":
 		return nativeBoolToBoolObject(leftVal == rightVal)
 	case "!=":
@@ -299,6 +376,12 @@ func evalWhileExpression(we *ast.WhileExpression, env *object.Enviroment) object
 		if isTruthy(condition) {
 			looped = true
 			output = Eval(we.Body, env)
+			if output != nil {
+				rt := output.Type()
+				if rt == object.ReturnValueObj || rt == object.ErrorObj || rt == object.TailCallObj {
+					return output
+				}
+			}
 		} else {
 			if looped {
 				return output
@@ -313,10 +396,18 @@ func evalIdentifier(node *ast.Identifier, env *object.Enviroment) object.Object
 		return val
 	}
 
+	if global, ok := env.Runtime().Globals[node.Value]; ok {
+		return global
+	}
+
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
 
+	if constant, ok := constants[node.Value]; ok {
+		return constant
+	}
+
 	return newError("identifier not found: " + node.Value)
 }
 
@@ -334,17 +425,68 @@ func evalExpressions(exps []ast.Expression, env *object.Enviroment) []object.Obj
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	switch fn := fn.(type) {
-	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
-	case *object.Builtin:
-		return fn.Fn(args...)
-	default:
-		return newError("not a function: %s", fn.Type())
+// callBody evaluates a function's body, counting the call in the runtime's
+// depth until it returns or panics
+func callBody(runtime *object.Runtime, f *object.Function, args []object.Object) object.Object {
+	runtime.CallDepth++
+	defer func() { runtime.CallDepth-- }()
+	return Eval(f.Body, extendFunctionEnv(f, args))
+}
+
+// applyFunction calls a function or builtin, env is the caller's enviroment
+// which is handed to builtins
+func applyFunction(fn object.Object, args []object.Object, env *object.Enviroment) object.Object {
+	for {
+		switch f := fn.(type) {
+		case *object.Function:
+			runtime := f.Env.Runtime()
+			if runtime.CallDepth >= maxCallDepth {
+				return newError("maximum recursion depth exceeded: %d", maxCallDepth)
+			}
+			if len(args) != len(f.Parameters) {
+				return newError("wrong number of arguments. got=%d, want=%d",
+					len(args), len(f.Parameters))
+			}
+
+			evaluated := callBody(runtime, f, args)
+
+			// a tail call reuses this frame rather than recursing
+			if tailCall, ok := evaluated.(*object.TailCall); ok {
+				fn, args = tailCall.Function, tailCall.Arguments
+				continue
+			}
+
+			return unwrapReturnValue(evaluated)
+		case *object.Builtin:
+			return f.Fn(env, args...)
+		default:
+			return newError("not a function: %s", fn.Type())
+		}
+	}
+}
+
+// evalTailCall evaluates `return f(...)`, handing user functions back to
+// applyFunction as a TailCall so they run in constant stack space
+func evalTailCall(call *ast.CallExpression, env *object.Enviroment) object.Object {
+	function := Eval(call.Function, env)
+	if isError(function) {
+		return function
+	}
+
+	args := evalExpressions(call.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if _, ok := function.(*object.Function); !ok {
+		val := applyFunction(function, args, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
 	}
+
+	return &object.TailCall{Function: function, Arguments: args}
 }
 
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Enviroment {
@@ -369,6 +511,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.StringObj && index.Type() == object.IntegerObj:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HashObj:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -382,6 +526,11 @@ func evalArrayIndexExpression(left, index object.Object) object.Object {
 	i := index.(*object.Integer).Value
 	max := int64(len(array.Elements) - 1)
 
+	// negative indices count back from the end
+	if i < 0 {
+		i += max + 1
+	}
+
 	if i < 0 || i > max {
 		return nullObj
 	}
@@ -389,10 +538,125 @@ func evalArrayIndexExpression(left, index object.Object) object.Object {
 	return array.Elements[i]
 }
 
+func evalStringIndexExpression(left, index object.Object) object.Object {
+	chars := []rune(left.(*object.String).Value)
+	i := index.(*object.Integer).Value
+	max := int64(len(chars) - 1)
+
+	if i < 0 {
+		i += max + 1
+	}
+
+	if i < 0 || i > max {
+		return nullObj
+	}
+
+	return &object.String{Value: string(chars[i])}
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Enviroment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := [3]*int64{}
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		val := Eval(exp, env)
+		if isError(val) {
+			return val
+		}
+		integer, ok := val.(*object.Integer)
+		if !ok {
+			return newError("slice indices must be INTEGER, got %s", val.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+
+	step := int64(1)
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return newError("slice step cannot be zero")
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		indices := sliceIndices(int64(len(left.Elements)), bounds[0], bounds[1], step)
+		elements := make([]object.Object, len(indices))
+		for i, index := range indices {
+			elements[i] = left.Elements[index]
+		}
+		return &object.Array{Elements: elements}
+	case *object.String:
+		chars := []rune(left.Value)
+		indices := sliceIndices(int64(len(chars)), bounds[0], bounds[1], step)
+		out := make([]rune, len(indices))
+		for i, index := range indices {
+			out[i] = chars[index]
+		}
+		return &object.String{Value: string(out)}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// sliceIndices lists the positions picked out by a slice of a sequence with
+// the given length, missing bounds default to the whole sequence in the
+// direction of step and out of range bounds are clamped
+func sliceIndices(length int64, start, end *int64, step int64) []int64 {
+	lower, upper := int64(0), length
+	if step < 0 {
+		lower, upper = -1, length-1
+	}
+
+	clamp := func(bound *int64, def int64) int64 {
+		if bound == nil {
+			return def
+		}
+		i := *bound
+		if i < 0 {
+			i += length
+			if i < lower {
+				i = lower
+			}
+		} else if i > upper {
+			i = upper
+		}
+		return i
+	}
+
+	var from, to int64
+	if step > 0 {
+		from, to = clamp(start, lower), clamp(end, upper)
+	} else {
+		from, to = clamp(start, upper), clamp(end, lower)
+	}
+
+	// counting up front keeps a huge step from overflowing the index
+	var count int64
+	if step > 0 && from < to {
+		count = (to-from-1)/step + 1
+	} else if step < 0 && from > to {
+		count = 1 - (from-to-1)/step
+	}
+
+	indices := make([]int64, count)
+	for k := range indices {
+		indices[k] = from + int64(k)*step
+	}
+
+	return indices
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Enviroment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
@@ -403,17 +667,15 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Enviroment) object.Objec
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(left, index object.Object) object.Object {
@@ -423,7 +685,7 @@ func evalHashIndexExpression(left, index object.Object) object.Object {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 	if !ok {
 		return nullObj
 	}
@@ -4,6 +4,7 @@ import (
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
 	"testing"
 )
 
@@ -331,6 +332,7 @@ func TestFunctionApplication(t *testing.T) {
 		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
 		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
 		{"fn(x) { x; }(5)", 5},
+		{"let add = fn(x: int, y: int) -> int { x + y; }; let n: int = add(5, 5); n", 10},
 	}
 
 	for _, tt := range tests {
@@ -495,6 +497,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -550,6 +560,25 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestHashInspectOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"c": 1, "b": 2, "a": 3}`, "{c: 1, b: 2, a: 3}"},
+		{`{3: "x", 1: "y", true: "z"}`, "{3: x, 1: y, true: z}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -595,3 +624,530 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`let count = fn(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); };
+			count(100000, 0);`,
+			100000,
+		},
+		{
+			`let even = fn(n) { if (n == 0) { return 1; } return odd(n - 1); };
+			let odd = fn(n) { if (n == 0) { return 0; } return even(n - 1); };
+			even(50001);`,
+			0,
+		},
+		{
+			`let last = fn(arr) { if (len(arr) == 1) { return first(arr); } return last(rest(arr)); };
+			last([1, 2, 3, 4, 5]);`,
+			5,
+		},
+		{
+			`let find = fn(n) { let i = 0; while (true) { if (i == n) { return i; } let i = i + 1; } };
+			find(10);`,
+			10,
+		},
+		{"return len([1, 2, 3]);", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMaximumRecursionDepth(t *testing.T) {
+	input := `let sum = fn(n) { if (n == 0) { return 0; } n + sum(n - 1) };
+	sum(100000);`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := "maximum recursion depth exceeded: 10000"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+
+	testIntegerObject(t, testEval(`let sum = fn(n) { if (n == 0) { return 0; } n + sum(n - 1) };
+	sum(100);`), 5050)
+}
+
+func TestRecursionDepthPerRuntime(t *testing.T) {
+	deep, fresh := object.NewRuntime(), object.NewRuntime()
+	deep.CallDepth = maxCallDepth
+
+	input := `let f = fn() { 1 }; f()`
+	if got := testEvalWithRuntime(input, deep).Inspect(); got != "Error: maximum recursion depth exceeded: 10000" {
+		t.Errorf("wrong result at the maximum depth. got=%q", got)
+	}
+	testIntegerObject(t, testEvalWithRuntime(input, fresh), 1)
+	if fresh.CallDepth != 0 {
+		t.Errorf("depth not restored after the call. got=%d", fresh.CallDepth)
+	}
+
+	fresh.Globals["boom"] = &object.Builtin{Fn: func(env *object.Enviroment, args ...object.Object) object.Object {
+		panic("boom")
+	}}
+	func() {
+		defer func() { recover() }()
+		testEvalWithRuntime(`let f = fn() { boom() }; f()`, fresh)
+	}()
+	if fresh.CallDepth != 0 {
+		t.Errorf("depth not restored after a panic. got=%d", fresh.CallDepth)
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`keys({"b": 1, "a": 2, 3: 3})`, "[b, a, 3]"},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`items({"b": 1, "a": 2})`, "[[b, 1], [a, 2]]"},
+		{`keys({})`, "[]"},
+		{`keys([])`, "Error: argument to `keys` must be HASH, got ARRAY"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({"a": 1}, [])`, "Error: unusable as hash key: ARRAY"},
+		{`get({"a": 1}, "a")`, "1"},
+		{`get({"a": 1}, "b")`, "null"},
+		{`get({"a": 1}, "b", 5)`, "5"},
+		{`get({"a": 1})`, "Error: wrong number of arguments. got=1, want=2 or 3"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b")`, "2"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b"); h`, "{a: 1, c: 3}"},
+		{`let h = {"a": 1}; delete(h, "z"); h`, "{a: 1}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`let h = {"a": 1}; merge(h, {"b": 2}); h`, "{a: 1}"},
+		{`merge({"a": 1}, [])`, "Error: argument to `merge` must be HASH, got ARRAY"},
+		{`let h = {"a": 1}; replace(h, "b", 2); h`, "{a: 1, b: 2}"},
+		{`let h = {"a": 1}; replace(h, "a", 2); h`, "{a: 2}"},
+		{`replace(1, 2, 3)`, "Error: argument to `replace` must be ARRAY or HASH, got INTEGER"},
+		{`len({"a": 1, "b": 2})`, "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTypeAndErrorBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`type({})`, "HASH"},
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type(fn(x) { x })`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`type()`, "Error: wrong number of arguments. got=0, want=1"},
+		{`error("boom"); 1`, "Error: boom"},
+		{`let f = fn() { error([1]); 2 }; f()`, "Error: [1]"},
+		{`error()`, "Error: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`map("abc", fn(c) { c + c })`, "[aa, bb, cc]"},
+		{`map({"a": 1, "b": 2}, fn(k, v) { [k, v * 10] })`, "[[a, 10], [b, 20]]"},
+		{`map([1, 2], len)`, "Error: argument to `len` not supported, got INTEGER"},
+		{`map([[1], [1, 2]], len)`, "[1, 2]"},
+		{`map([1], 5)`, "Error: not a function: INTEGER"},
+		{`map([1], fn(a, b) { a })`, "Error: wrong number of arguments. got=1, want=2"},
+		{`map(1, fn(x) { x })`, "Error: argument to `map` must be ARRAY, STRING or HASH, got INTEGER"},
+		{`filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })`, "[2, 4]"},
+		{`filter("hello", fn(c) { c != "l" })`, "heo"},
+		{`filter({"a": 1, "b": 2, "c": 3}, fn(k, v) { v != 2 })`, "{a: 1, c: 3}"},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, "10"},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, "16"},
+		{`reduce([], fn(acc, x) { acc + x })`, "null"},
+		{`reduce("abc", fn(acc, c) { c + acc }, "")`, "cba"},
+		{`let total = [0]; each([1, 2, 3], fn(x) { push(total, x) }); total`, "[0, 1, 2, 3]"},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, "true"},
+		{`any([1, 2, 3], fn(x) { x > 3 })`, "false"},
+		{`any([0, "", 5])`, "true"},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, "false"},
+		{`all([])`, "true"},
+		{`find([1, 2, 3, 4], fn(x) { x > 2 })`, "3"},
+		{`find([1, 2], fn(x) { x > 2 })`, "null"},
+		{`find({"a": 1, "b": 2}, fn(k, v) { v == 2 })`, "[b, 2]"},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort("cab")`, "[a, b, c]"},
+		{`let a = [3, 1, 2]; sort(a); a`, "[3, 1, 2]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort([1, "a"])`, "Error: cannot compare STRING and INTEGER"},
+		{`sort_by(["ccc", "a", "bb"], len)`, "[a, bb, ccc]"},
+		{`sort_by([[2, "x"], [1, "y"], [2, "z"]], first)`, "[[1, y], [2, x], [2, z]]"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`zip([1], [2], [3])`, "[[1, 2, 3]]"},
+		{`enumerate(["a", "b"])`, "[[0, a], [1, b]]"},
+		{`flat_map([1, 2], fn(x) { [x, x] })`, "[1, 1, 2, 2]"},
+		{`flat_map([1, 2], fn(x) { x })`, "[1, 2]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a,b,c", ",")`, "[a, b, c]"},
+		{`split("  a b  c ")`, "[a, b, c]"},
+		{`split(1, ",")`, "Error: argument to `split` must be STRING, got INTEGER"},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join([1, 2, 3])`, "123"},
+		{`trim("  hi  ")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`upper("Hello")`, "HELLO"},
+		{`lower("Hello")`, "hello"},
+		{`contains("monkey", "key")`, "true"},
+		{`contains("monkey", "cat")`, "false"},
+		{`contains([1, "a"], "a")`, "true"},
+		{`starts_with("monkey", "mon")`, "true"},
+		{`ends_with("monkey", "mon")`, "false"},
+		{`index_of("héllo", "l")`, "2"},
+		{`index_of("hello", "z")`, "-1"},
+		{`index_of([5, 6, 7], 7)`, "2"},
+		{`replace_all("a-b-c", "-", "+")`, "a+b+c"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, "Error: repeat count must not be negative, got -1"},
+		{`repeat("ab", 9223372036854775807)`, "Error: repeat result too long, got count 9223372036854775807 for a string of length 2"},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`chars("héy")`, "[h, é, y]"},
+		{`substr("monkey", 3)`, "key"},
+		{`substr("monkey", 1, 3)`, "onk"},
+		{`substr("monkey", 4, 10)`, "ey"},
+		{`substr("abc", 1, 9223372036854775807)`, "bc"},
+		{`substr("monkey", 7)`, "Error: substr start out of range, got=7, string length=6"},
+		{`format("{} + {} = {}", 1, 2, "three")`, "1 + 2 = three"},
+		{`format("{} {}", 1)`, "Error: format expects 2 arguments, got=1"},
+		{`len("héllo")`, "5"},
+		{`"héllo"[1]`, "é"},
+		{`"hello"[5]`, "null"},
+		{`"apple" < "banana"`, "true"},
+		{`"apple" > "banana"`, "false"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4, 5][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4, 5][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4, 5][3:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:]", "[1, 2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][::2]", "[1, 3, 5]"},
+		{"[1, 2, 3, 4, 5][1:4:2]", "[2, 4]"},
+		{"[1, 2, 3, 4, 5][-2:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:-2]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4, 5][::-1]", "[5, 4, 3, 2, 1]"},
+		{"[1, 2, 3, 4, 5][3:0:-1]", "[4, 3, 2]"},
+		{"[1, 2, 3, 4, 5][10:20]", "[]"},
+		{"[1, 2, 3, 4, 5][-10:2]", "[1, 2]"},
+		{"[1, 2, 3][::0]", "Error: slice step cannot be zero"},
+		{`[1, 2, 3]["a":]`, "Error: slice indices must be INTEGER, got STRING"},
+		{`let a = [1, 2, 3]; a[0:1]; a`, "[1, 2, 3]"},
+		{`"monkey"[1:3]`, "on"},
+		{`"monkey"[-3:]`, "key"},
+		{`"héllo"[::-1]`, "olléh"},
+		{`"monkey"[-1]`, "y"},
+		{"[1, 2, 3][1::9223372036854775807]", "[2]"},
+		{`"abc"[2::9223372036854775807]`, "c"},
+		{"[1, 2, 3][1::-9223372036854775807 - 1]", "[2]"},
+		{`"abc"[::-9223372036854775807]`, "c"},
+		{`5[1:]`, "Error: slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5", "1.5"},
+		{"2.0", "2.0"},
+		{"-2.5", "-2.5"},
+		{"1.5 + 1.5", "3.0"},
+		{"1 + 0.5", "1.5"},
+		{"7 / 2", "3"},
+		{"7 / 2.0", "3.5"},
+		{"7.5 % 2", "1.5"},
+		{"0.1 * 3 > 0.3", "true"},
+		{"1 == 1.0", "true"},
+		{"2.5 != 2.5", "false"},
+		{"1 / 0", "Error: division by zero"},
+		{"1 % 0", "Error: division by zero"},
+		{"1.0 / 0", "Error: division by zero"},
+		{"bool(0.0)", "false"},
+		{"sort([3, 1.5, 2])", "[1.5, 2, 3]"},
+		{`{1.5: "a"}[1.5]`, "a"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"abs(-5)", "5"},
+		{"abs(-2.5)", "2.5"},
+		{`abs("a")`, "Error: argument to `abs` must be INTEGER or FLOAT, got STRING"},
+		{"min(3, 1, 2)", "1"},
+		{"max(3, 1.5, 2)", "3"},
+		{"max([1, 4.5, 2])", "4.5"},
+		{"min([])", "Error: `min` needs at least one value"},
+		{"pow(2, 10)", "1024"},
+		{"pow(2, -1)", "0.5"},
+		{"pow(4, 0.5)", "2.0"},
+		{"pow(0, -1)", "Error: math domain error: zero to a negative power"},
+		{"pow(-8, 0.5)", "Error: math domain error: pow(-8, 0.5)"},
+		{"pow(2, 62)", "4611686018427387904"},
+		{"pow(-2, 63)", "-9223372036854775808"},
+		{"pow(2, 63)", "Error: math range error: pow(2, 63) does not fit in an INTEGER"},
+		{"pow(2, 64)", "Error: math range error: pow(2, 64) does not fit in an INTEGER"},
+		{"pow(3, 100)", "Error: math range error: pow(3, 100) does not fit in an INTEGER"},
+		{"pow(-1, 9223372036854775807)", "-1"},
+		{"sqrt(16)", "4.0"},
+		{"sqrt(-1)", "Error: math domain error: `sqrt` is not defined for -1"},
+		{"floor(2.7)", "2"},
+		{"floor(-2.5)", "-3"},
+		{"ceil(2.1)", "3"},
+		{"round(2.5)", "3"},
+		{"round(7)", "7"},
+		{"floor(pow(10.0, 30))", "Error: math range error: 1e+30 does not fit in an INTEGER"},
+		{"clamp(15, 0, 10)", "10"},
+		{"clamp(-1.5, 0, 10)", "0"},
+		{"clamp(5, 0, 10)", "5"},
+		{"clamp(5, 10, 0)", "Error: clamp lower bound 10 is above upper bound 0"},
+		{"gcd(12, 18)", "6"},
+		{"gcd(-4, 6)", "2"},
+		{"lcm(4, 6)", "12"},
+		{"lcm(0, 6)", "0"},
+		{"lcm(-4, 6)", "12"},
+		{"lcm(9223372036854775807, 2)", "Error: math range error: lcm(9223372036854775807, 2) does not fit in an INTEGER"},
+		{"lcm(-9223372036854775807 - 1, 1)", "Error: math range error: lcm(-9223372036854775808, 1) does not fit in an INTEGER"},
+		{"lcm(4611686018427387904, 2)", "4611686018427387904"},
+		{"gcd(-9223372036854775807 - 1, 6)", "2"},
+		{"gcd(-9223372036854775807 - 1, 0)", "Error: math range error: gcd(-9223372036854775808, 0) does not fit in an INTEGER"},
+		{"gcd(1.5, 2)", "Error: argument to `gcd` must be INTEGER, got FLOAT"},
+		{"round(sin(PI / 2))", "1"},
+		{"cos(0)", "1.0"},
+		{"tan(0)", "0.0"},
+		{"asin(2)", "Error: math domain error: `asin` is not defined for 2"},
+		{"round(acos(-1) * 1000)", "3142"},
+		{"atan2(0, 1)", "0.0"},
+		{"log(E)", "1.0"},
+		{"log(8, 2)", "3.0"},
+		{"log(0)", "Error: math domain error: `log` is not defined for 0"},
+		{"log(8, 1)", "Error: math domain error: invalid logarithm base 1"},
+		{"exp(0)", "1.0"},
+		{"int(3.9)", "3"},
+		{"int(-3.9)", "-3"},
+		{`int("42")`, "42"},
+		{`int("x")`, `Error: could not convert "x" to INTEGER`},
+		{"float(2)", "2.0"},
+		{`float("2.5")`, "2.5"},
+		{"PI > 3.14", "true"},
+		{"let PI = 3; PI", "3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRandomBuiltins(t *testing.T) {
+	draw := `seed(42); [random(1000), random(1000), random_range(10, 20), random(), choice(["a", "b", "c"]), shuffle([1, 2, 3, 4, 5])]`
+
+	first := testEval(draw).Inspect()
+	second := testEval(draw).Inspect()
+	if first != second {
+		t.Errorf("seeded runs differ. first=%q, second=%q", first, second)
+	}
+
+	// seeding one interpreter leaves the others alone
+	env := object.NewEnviroment()
+	other := object.NewEnviroment()
+	other.Runtime().Seed(42)
+	Eval(parser.New(lexer.New("seed(1)")).ParseProgram(), env)
+	fromOther := Eval(parser.New(lexer.New("random(1000000)")).ParseProgram(), other)
+	fromSeeded := testEval("seed(42); random(1000000)")
+	if fromOther.Inspect() != fromSeeded.Inspect() {
+		t.Errorf("seed leaked between interpreters. got=%s, want=%s", fromOther.Inspect(), fromSeeded.Inspect())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let ok = true; let i = 0; while (i < 200) { let x = random_range(-3, 3); if (x < -3) { let ok = false; } if (x > 2) { let ok = false; } let i = i + 1; }; ok`, "true"},
+		{`let ok = true; let i = 0; while (i < 200) { let x = random_range(0.5, 1.5); if (x < 0.5) { let ok = false; } if (x > 1.5) { let ok = false; } let i = i + 1; }; ok`, "true"},
+		{`type(random())`, "FLOAT"},
+		{`random(0)`, "Error: cap value must be at least 1, got 0"},
+		{`random_range(5, 5)`, "Error: random_range needs lo < hi, got 5 and 5"},
+		{`type(random_range(-9223372036854775807, 9223372036854775807))`, "INTEGER"},
+		{`let lo = -9223372036854775807 - 1; let ok = true; let i = 0; while (i < 200) { if (random_range(lo, 0) > -1) { let ok = false; } let i = i + 1; }; ok`, "true"},
+		{`let hi = 9223372036854775807; let ok = true; let i = 0; while (i < 200) { if (random_range(-hi, hi) == hi) { let ok = false; } let i = i + 1; }; ok`, "true"},
+		{`choice([])`, "Error: cannot choose from an empty ARRAY"},
+		{`contains("abc", choice("abc"))`, "true"},
+		{`sort(shuffle([3, 1, 2, 5, 4]))`, "[1, 2, 3, 4, 5]"},
+		{`let a = [1, 2, 3]; shuffle(a) == a`, "true"},
+		{`seed("x")`, "Error: argument to `seed` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_encode({"b": 1, "a": [true, null, 1.5, "x\"y"]})`, `{"b":1,"a":[true,null,1.5,"x\"y"]}`},
+		{`json_encode("<tag>")`, `"<tag>"`},
+		{`json_encode([])`, `[]`},
+		{`json_encode({"a": [1]}, 2)`, "{\n  \"a\": [\n    1\n  ]\n}"},
+		{`json_encode({"a": 1}, "\t")`, "{\n\t\"a\": 1\n}"},
+		{`json_encode([1], 9223372036854775807)`, "Error: json_encode indent must be at most 32, got 9223372036854775807"},
+		{`json_encode({1: 2})`, "Error: json_encode: hash keys must be STRING, got INTEGER"},
+		{`json_encode([fn(x) { x }])`, "Error: json_encode: cannot encode FUNCTION"},
+		{`json_encode(len)`, "Error: json_encode: cannot encode BUILTIN"},
+		{`let a = []; push(a, a); json_encode(a)`, "Error: json_encode: cannot encode a value that contains itself"},
+		{`let a = [1]; json_encode([a, a])`, "[[1],[1]]"},
+		{`json_decode("{\"z\": 1, \"a\": [1.5, 2e3, true, null, \"s\"]}")`, "{z: 1, a: [1.5, 2000.0, true, null, s]}"},
+		{`[type(json_decode("12")), type(json_decode("12.0")), type(json_decode("1e2"))]`, "[INTEGER, FLOAT, FLOAT]"},
+		{`json_decode("[1, 2,]")`, "Error: json_decode: invalid character ',' looking for beginning of value at line 1, column 6"},
+		{`json_decode("{\n  \"a\": tru\n}")`, "Error: json_decode: invalid character '\\n' in literal true (expecting 'e') at line 2, column 11"},
+		{`json_decode("[1, 2")`, "Error: json_decode: unexpected end of JSON input at line 1, column 6"},
+		{`json_decode("1 2")`, "Error: json_decode: unexpected data after the value at line 1, column 4"},
+		{`json_decode("")`, "Error: json_decode: unexpected end of JSON input at line 1, column 1"},
+		{`json_decode(1)`, "Error: argument to `json_decode` must be STRING, got INTEGER"},
+		{`let v = {"n": [1, {"m": "x"}]}; json_encode(json_decode(json_encode(v))) == json_encode(v)`, "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSystemBuiltins(t *testing.T) {
+	os.Setenv("MONKEY_TEST_VALUE", "set")
+	defer os.Unsetenv("MONKEY_TEST_VALUE")
+	defer os.Unsetenv("MONKEY_TEST_NEW")
+
+	exitCode := -1
+	runtime := object.NewRuntime()
+	runtime.Exit = func(code int) { exitCode = code }
+	runtime.Globals["ARGS"] = &object.Array{Elements: []object.Object{&object.String{Value: "-v"}}}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`ARGS`, "[-v]"},
+		{`let f = fn() { ARGS[0] }; f()`, "-v"},
+		{`let ARGS = 1; ARGS`, "1"},
+		{`env_get("MONKEY_TEST_VALUE")`, "set"},
+		{`env_get("MONKEY_TEST_MISSING")`, "null"},
+		{`env_get("MONKEY_TEST_MISSING", "fallback")`, "fallback"},
+		{`env_set("MONKEY_TEST_NEW", "new"); env_get("MONKEY_TEST_NEW")`, "new"},
+		{`env_set("MONKEY_TEST_NEW", 1)`, "Error: argument to `env_set` must be STRING, got INTEGER"},
+		{`env_get(1)`, "Error: argument to `env_get` must be STRING, got INTEGER"},
+		{`exit("1")`, "Error: argument to `exit` must be INTEGER, got STRING"},
+		{`exit(256)`, "Error: exit code must be between 0 and 255, got 256"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithRuntime(tt.input, runtime)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	if exitCode != -1 {
+		t.Fatalf("exit called for an invalid code, got=%d", exitCode)
+	}
+	testEvalWithRuntime(`exit(3)`, runtime)
+	if exitCode != 3 {
+		t.Errorf("wrong exit code. expected=3, got=%d", exitCode)
+	}
+	testEvalWithRuntime(`exit()`, runtime)
+	if exitCode != 0 {
+		t.Errorf("wrong exit code. expected=0, got=%d", exitCode)
+	}
+}
@@ -0,0 +1,200 @@
+package evaluator
+
+import (
+	"bufio"
+	"io/ioutil"
+	"monkey/object"
+	"os"
+	"sort"
+)
+
+// The file system builtins only work once the runtime has been given access
+// with AllowFS, any failure comes back as an error value
+
+func init() {
+	// each_line calls back into the evaluator, see higher_order.go
+	builtins["each_line"] = &object.Builtin{Fn: eachLineBuiltin}
+}
+
+// fsPath checks the path argument of a file system builtin against the
+// runtime's allowed roots
+func fsPath(env *object.Enviroment, name string, arg object.Object) (string, object.Object) {
+	str, ok := arg.(*object.String)
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got %s", name, arg.Type())
+	}
+
+	path, err := env.Runtime().FSPath(str.Value)
+	if err != nil {
+		return "", newError("%s: %s", name, err)
+	}
+	return path, nil
+}
+
+func readFileBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	path, errObj := fsPath(env, "read_file", args[0])
+	if errObj != nil {
+		return errObj
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newError("read_file: %s", err)
+	}
+	return &object.String{Value: string(data)}
+}
+
+func writeFileBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	return writeFile(env, "write_file", os.O_TRUNC, args)
+}
+
+func appendFileBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	return writeFile(env, "append_file", os.O_APPEND, args)
+}
+
+func writeFile(env *object.Enviroment, name string, mode int, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	path, errObj := fsPath(env, name, args[0])
+	if errObj != nil {
+		return errObj
+	}
+	content, ok := args[1].(*object.String)
+	if !ok {
+		return newError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|mode, 0644)
+	if err != nil {
+		return newError("%s: %s", name, err)
+	}
+	if _, err := f.WriteString(content.Value); err != nil {
+		f.Close()
+		return newError("%s: %s", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return newError("%s: %s", name, err)
+	}
+	return nullObj
+}
+
+func existsBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	path, errObj := fsPath(env, "exists", args[0])
+	if errObj != nil {
+		return errObj
+	}
+
+	_, err := os.Stat(path)
+	return nativeBoolToBoolObject(err == nil)
+}
+
+// list_dir returns the sorted names of a directory's entries
+func listDirBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	path, errObj := fsPath(env, "list_dir", args[0])
+	if errObj != nil {
+		return errObj
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return newError("list_dir: %s", err)
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return newError("list_dir: %s", err)
+	}
+	sort.Strings(names)
+
+	elements := make([]object.Object, len(names))
+	for i, name := range names {
+		elements[i] = &object.String{Value: name}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// mkdir creates a directory along with any missing parents
+func mkdirBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	path, errObj := fsPath(env, "mkdir", args[0])
+	if errObj != nil {
+		return errObj
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return newError("mkdir: %s", err)
+	}
+	return nullObj
+}
+
+// remove deletes a file or an empty directory
+func removeBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	path, errObj := fsPath(env, "remove", args[0])
+	if errObj != nil {
+		return errObj
+	}
+
+	if err := os.Remove(path); err != nil {
+		return newError("remove: %s", err)
+	}
+	return nullObj
+}
+
+// each_line(path, fn) calls fn with every line of a file without reading the
+// whole file into memory, the line endings are stripped
+func eachLineBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	path, errObj := fsPath(env, "each_line", args[0])
+	if errObj != nil {
+		return errObj
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return newError("each_line: %s", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+		result := callFunction(env, args[1], &object.String{Value: line})
+		if isError(result) {
+			return result
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return newError("each_line: %s", err)
+	}
+
+	return nullObj
+}
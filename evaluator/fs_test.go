@@ -0,0 +1,82 @@
+package evaluator
+
+import (
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testEvalWithRuntime(input string, runtime *object.Runtime) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	return Eval(program, object.NewModuleEnviroment(runtime, ""))
+}
+
+func TestFileSystemBuiltins(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"data/a.txt":     "one\ntwo\r\nthree",
+		"data/empty.txt": "",
+	})
+	outside := writeModules(t, map[string]string{"secret.txt": "shh"})
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := object.NewRuntime()
+	if err := runtime.AllowFS(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	path := func(name string) string {
+		return strings.ReplaceAll(filepath.Join(dir, name), `\`, `\\`)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`read_file("` + path("data/a.txt") + `")`, "one\ntwo\r\nthree"},
+		{`let lines = []; each_line("` + path("data/a.txt") + `", fn(l) { push(lines, l) }); lines`, "[one, two, three]"},
+		{`let n = 0; each_line("` + path("data/empty.txt") + `", fn(l) { let n = n + 1 }); n`, "0"},
+		{`each_line("` + path("data/a.txt") + `", fn(l) { error("stop at " + l) })`, "Error: stop at one"},
+		{`write_file("` + path("out.txt") + `", "a"); append_file("` + path("out.txt") + `", "b"); read_file("` + path("out.txt") + `")`, "ab"},
+		{`write_file("` + path("out.txt") + `", "c"); read_file("` + path("out.txt") + `")`, "c"},
+		{`[exists("` + path("data") + `"), exists("` + path("nope") + `")]`, "[true, false]"},
+		{`list_dir("` + path("data") + `")`, "[a.txt, empty.txt]"},
+		{`mkdir("` + path("x/y") + `"); exists("` + path("x/y") + `")`, "true"},
+		{`remove("` + path("x/y") + `"); exists("` + path("x/y") + `")`, "false"},
+		{`write_file("` + path("gone.txt") + `", ""); remove("` + path("gone.txt") + `")`, "null"},
+		{`read_file(1)`, "Error: argument to `read_file` must be STRING, got INTEGER"},
+		{`write_file("` + path("out.txt") + `", 1)`, "Error: second argument to `write_file` must be STRING, got INTEGER"},
+		{`read_file("` + path("link.txt") + `")`, "Error: read_file: access denied: " + filepath.Join(dir, "link.txt") + " is outside the allowed directories"},
+		{`read_file("` + path("../secret.txt") + `")`, "Error: read_file: access denied: " + filepath.Join(dir, "../secret.txt") + " is outside the allowed directories"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithRuntime(tt.input, runtime)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	missing := testEvalWithRuntime(`read_file("`+path("nope")+`")`, runtime)
+	if !strings.HasPrefix(missing.Inspect(), "Error: read_file: open ") {
+		t.Errorf("expected an open error, got=%q", missing.Inspect())
+	}
+}
+
+func TestFileSystemDisabledByDefault(t *testing.T) {
+	names := []string{"read_file", "exists", "list_dir", "mkdir", "remove"}
+	for _, name := range names {
+		evaluated := testEval(name + `(".")`)
+		expected := "Error: " + name + ": file system access is disabled, run with --allow-fs"
+		if evaluated.Inspect() != expected {
+			t.Errorf("expected=%q, got=%q", expected, evaluated.Inspect())
+		}
+	}
+}
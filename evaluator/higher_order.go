@@ -0,0 +1,447 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"sort"
+)
+
+// The higher order builtins call back into the evaluator, so they are added
+// to the builtins map here rather than in its literal, which would otherwise
+// be an initialization cycle.
+func init() {
+	builtins["map"] = &object.Builtin{Fn: mapBuiltin}
+	builtins["filter"] = &object.Builtin{Fn: filterBuiltin}
+	builtins["reduce"] = &object.Builtin{Fn: reduceBuiltin}
+	builtins["each"] = &object.Builtin{Fn: eachBuiltin}
+	builtins["any"] = &object.Builtin{Fn: anyBuiltin}
+	builtins["all"] = &object.Builtin{Fn: allBuiltin}
+	builtins["find"] = &object.Builtin{Fn: findBuiltin}
+	builtins["sort"] = &object.Builtin{Fn: sortBuiltin}
+	builtins["sort_by"] = &object.Builtin{Fn: sortByBuiltin}
+	builtins["zip"] = &object.Builtin{Fn: zipBuiltin}
+	builtins["enumerate"] = &object.Builtin{Fn: enumerateBuiltin}
+	builtins["flat_map"] = &object.Builtin{Fn: flatMapBuiltin}
+}
+
+// callFunction lets a builtin invoke a monkey function or another builtin,
+// env is the enviroment the calling builtin was called from
+func callFunction(env *object.Enviroment, fn object.Object, args ...object.Object) object.Object {
+	switch fn.(type) {
+	case *object.Function, *object.Builtin:
+		return applyFunction(fn, args, env)
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+// callbackArgs turns each entry of a collection into the arguments passed to
+// a callback: the element for arrays, the character for strings and the key
+// and value for hashes
+func callbackArgs(name string, collection object.Object) ([][]object.Object, object.Object) {
+	var calls [][]object.Object
+
+	switch collection := collection.(type) {
+	case *object.Array:
+		for _, el := range collection.Elements {
+			calls = append(calls, []object.Object{el})
+		}
+	case *object.String:
+		for _, ch := range collection.Value {
+			calls = append(calls, []object.Object{&object.String{Value: string(ch)}})
+		}
+	case *object.Hash:
+		for _, pair := range collection.Ordered() {
+			calls = append(calls, []object.Object{pair.Key, pair.Value})
+		}
+	default:
+		return nil, newError("argument to `%s` must be ARRAY, STRING or HASH, got %s",
+			name, collection.Type())
+	}
+
+	return calls, nil
+}
+
+// arrayArgument returns the elements of an array, or the characters of a string
+func arrayArgument(name string, arg object.Object) ([]object.Object, object.Object) {
+	switch arg := arg.(type) {
+	case *object.Array:
+		return arg.Elements, nil
+	case *object.String:
+		chars := []object.Object{}
+		for _, ch := range arg.Value {
+			chars = append(chars, &object.String{Value: string(ch)})
+		}
+		return chars, nil
+	default:
+		return nil, newError("argument to `%s` must be ARRAY or STRING, got %s", name, arg.Type())
+	}
+}
+
+func mapBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	calls, err := callbackArgs("map", args[0])
+	if err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, 0, len(calls))
+	for _, callArgs := range calls {
+		result := callFunction(env, args[1], callArgs...)
+		if isError(result) {
+			return result
+		}
+		elements = append(elements, result)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func filterBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	calls, err := callbackArgs("filter", args[0])
+	if err != nil {
+		return err
+	}
+
+	kept := [][]object.Object{}
+	for _, callArgs := range calls {
+		result := callFunction(env, args[1], callArgs...)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			kept = append(kept, callArgs)
+		}
+	}
+
+	switch args[0].(type) {
+	case *object.String:
+		out := ""
+		for _, callArgs := range kept {
+			out += callArgs[0].(*object.String).Value
+		}
+		return &object.String{Value: out}
+	case *object.Hash:
+		hash := object.NewHash()
+		for _, callArgs := range kept {
+			key := callArgs[0].(object.Hashable).HashKey()
+			hash.Set(key, object.HashPair{Key: callArgs[0], Value: callArgs[1]})
+		}
+		return hash
+	default:
+		elements := make([]object.Object, 0, len(kept))
+		for _, callArgs := range kept {
+			elements = append(elements, callArgs[0])
+		}
+		return &object.Array{Elements: elements}
+	}
+}
+
+func reduceBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+
+	elements, err := arrayArgument("reduce", args[0])
+	if err != nil {
+		return err
+	}
+
+	var acc object.Object
+	if len(args) == 3 {
+		acc = args[2]
+	} else if len(elements) > 0 {
+		acc, elements = elements[0], elements[1:]
+	} else {
+		return nullObj
+	}
+
+	for _, el := range elements {
+		acc = callFunction(env, args[1], acc, el)
+		if isError(acc) {
+			return acc
+		}
+	}
+
+	return acc
+}
+
+func eachBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	calls, err := callbackArgs("each", args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, callArgs := range calls {
+		result := callFunction(env, args[1], callArgs...)
+		if isError(result) {
+			return result
+		}
+	}
+
+	return nullObj
+}
+
+// truthyCalls calls the predicate on each entry of the collection until
+// stop is returned, the predicate is optional and defaults to truthiness
+func truthyCalls(env *object.Enviroment, name string, args []object.Object, stop bool) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	calls, err := callbackArgs(name, args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, callArgs := range calls {
+		result := callArgs[len(callArgs)-1]
+		if len(args) == 2 {
+			result = callFunction(env, args[1], callArgs...)
+			if isError(result) {
+				return result
+			}
+		}
+		if isTruthy(result) == stop {
+			return nativeBoolToBoolObject(stop)
+		}
+	}
+
+	return nativeBoolToBoolObject(!stop)
+}
+
+func anyBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	return truthyCalls(env, "any", args, true)
+}
+
+func allBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	return truthyCalls(env, "all", args, false)
+}
+
+func findBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	calls, err := callbackArgs("find", args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, callArgs := range calls {
+		result := callFunction(env, args[1], callArgs...)
+		if isError(result) {
+			return result
+		}
+		if !isTruthy(result) {
+			continue
+		}
+		if len(callArgs) == 2 {
+			return &object.Array{Elements: callArgs}
+		}
+		return callArgs[0]
+	}
+
+	return nullObj
+}
+
+// compareObjects orders numbers and strings, it is the default ordering for `sort`
+func compareObjects(a, b object.Object) (int, object.Object) {
+	switch {
+	case a.Type() == object.IntegerObj && b.Type() == object.IntegerObj:
+		av, bv := a.(*object.Integer).Value, b.(*object.Integer).Value
+		switch {
+		case av < bv:
+			return -1, nil
+		case av > bv:
+			return 1, nil
+		}
+		return 0, nil
+	case isNumber(a) && isNumber(b):
+		av, _ := toFloat(a)
+		bv, _ := toFloat(b)
+		switch {
+		case av < bv:
+			return -1, nil
+		case av > bv:
+			return 1, nil
+		}
+		return 0, nil
+	case a.Type() == object.StringObj && b.Type() == object.StringObj:
+		av, bv := a.(*object.String).Value, b.(*object.String).Value
+		switch {
+		case av < bv:
+			return -1, nil
+		case av > bv:
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, newError("cannot compare %s and %s", a.Type(), b.Type())
+	}
+}
+
+// sortObjects stable sorts a copy of elements, stopping at the first error
+func sortObjects(elements []object.Object, less func(a, b object.Object) (bool, object.Object)) object.Object {
+	sorted := make([]object.Object, len(elements))
+	copy(sorted, elements)
+
+	var err object.Object
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		ok, e := less(sorted[i], sorted[j])
+		if e != nil {
+			err = e
+		}
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Array{Elements: sorted}
+}
+
+// sort returns a sorted copy, the optional comparator is called with two
+// elements and returns true when the first should come before the second
+func sortBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	elements, err := arrayArgument("sort", args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		return sortObjects(elements, func(a, b object.Object) (bool, object.Object) {
+			cmp, err := compareObjects(a, b)
+			return cmp < 0, err
+		})
+	}
+
+	return sortObjects(elements, func(a, b object.Object) (bool, object.Object) {
+		result := callFunction(env, args[1], a, b)
+		if isError(result) {
+			return false, result
+		}
+		return isTruthy(result), nil
+	})
+}
+
+// sort_by returns a copy sorted by the key the function returns for each element
+func sortByBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	elements, err := arrayArgument("sort_by", args[0])
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[object.Object]object.Object, len(elements))
+	for _, el := range elements {
+		if _, ok := keys[el]; ok {
+			continue
+		}
+		key := callFunction(env, args[1], el)
+		if isError(key) {
+			return key
+		}
+		keys[el] = key
+	}
+
+	return sortObjects(elements, func(a, b object.Object) (bool, object.Object) {
+		cmp, err := compareObjects(keys[a], keys[b])
+		return cmp < 0, err
+	})
+}
+
+func zipBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) < 2 {
+		return newError("wrong number of arguments. got=%d, want at least 2", len(args))
+	}
+
+	lists := make([][]object.Object, len(args))
+	length := -1
+	for i, arg := range args {
+		elements, err := arrayArgument("zip", arg)
+		if err != nil {
+			return err
+		}
+		lists[i] = elements
+		if length == -1 || len(elements) < length {
+			length = len(elements)
+		}
+	}
+
+	zipped := make([]object.Object, length)
+	for i := 0; i < length; i++ {
+		tuple := make([]object.Object, len(lists))
+		for j, list := range lists {
+			tuple[j] = list[i]
+		}
+		zipped[i] = &object.Array{Elements: tuple}
+	}
+
+	return &object.Array{Elements: zipped}
+}
+
+func enumerateBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	elements, err := arrayArgument("enumerate", args[0])
+	if err != nil {
+		return err
+	}
+
+	enumerated := make([]object.Object, len(elements))
+	for i, el := range elements {
+		index := &object.Integer{Value: int64(i)}
+		enumerated[i] = &object.Array{Elements: []object.Object{index, el}}
+	}
+
+	return &object.Array{Elements: enumerated}
+}
+
+func flatMapBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	calls, err := callbackArgs("flat_map", args[0])
+	if err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for _, callArgs := range calls {
+		result := callFunction(env, args[1], callArgs...)
+		if isError(result) {
+			return result
+		}
+		if arr, ok := result.(*object.Array); ok {
+			elements = append(elements, arr.Elements...)
+		} else {
+			elements = append(elements, result)
+		}
+	}
+
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,246 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"monkey/object"
+	"strconv"
+	"strings"
+)
+
+// maxJSONIndent is the most spaces json_encode indents by
+const maxJSONIndent = 32
+
+// json_encode(value, indent) serializes a value, keeping the order of hash
+// keys. indent is an optional number of spaces or an indent string.
+func jsonEncodeBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *object.Integer:
+			if arg.Value < 0 {
+				return newError("json_encode indent must not be negative, got %d", arg.Value)
+			}
+			if arg.Value > maxJSONIndent {
+				return newError("json_encode indent must be at most %d, got %d", maxJSONIndent, arg.Value)
+			}
+			indent = strings.Repeat(" ", int(arg.Value))
+		case *object.String:
+			indent = arg.Value
+		default:
+			return newError("argument to `json_encode` must be INTEGER or STRING, got %s", arg.Type())
+		}
+	}
+
+	var out bytes.Buffer
+	enc := &jsonEncoder{out: &out, seen: map[object.Object]bool{}}
+	if err := enc.encode(args[0]); err != nil {
+		return newError("json_encode: %s", err)
+	}
+
+	if indent != "" {
+		var indented bytes.Buffer
+		json.Indent(&indented, out.Bytes(), "", indent)
+		return &object.String{Value: indented.String()}
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+type jsonEncoder struct {
+	out *bytes.Buffer
+	// seen holds the arrays and hashes being encoded, to catch cycles
+	seen map[object.Object]bool
+}
+
+func (e *jsonEncoder) encode(obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		e.out.WriteString("null")
+	case *object.Boolean:
+		e.out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		e.out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return errors.New("cannot encode " + obj.Inspect())
+		}
+		e.out.WriteString(strconv.FormatFloat(obj.Value, 'g', -1, 64))
+	case *object.String:
+		e.encodeString(obj.Value)
+	case *object.Array:
+		if e.seen[obj] {
+			return errors.New("cannot encode a value that contains itself")
+		}
+		e.seen[obj] = true
+		defer delete(e.seen, obj)
+
+		e.out.WriteString("[")
+		for i, el := range obj.Elements {
+			if i > 0 {
+				e.out.WriteString(",")
+			}
+			if err := e.encode(el); err != nil {
+				return err
+			}
+		}
+		e.out.WriteString("]")
+	case *object.Hash:
+		if e.seen[obj] {
+			return errors.New("cannot encode a value that contains itself")
+		}
+		e.seen[obj] = true
+		defer delete(e.seen, obj)
+
+		e.out.WriteString("{")
+		for i, pair := range obj.Ordered() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return errors.New("hash keys must be STRING, got " + string(pair.Key.Type()))
+			}
+			if i > 0 {
+				e.out.WriteString(",")
+			}
+			e.encodeString(key.Value)
+			e.out.WriteString(":")
+			if err := e.encode(pair.Value); err != nil {
+				return err
+			}
+		}
+		e.out.WriteString("}")
+	default:
+		return errors.New("cannot encode " + string(obj.Type()))
+	}
+
+	return nil
+}
+
+func (e *jsonEncoder) encodeString(s string) {
+	enc := json.NewEncoder(e.out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Encode ends every value with a newline
+	e.out.Truncate(e.out.Len() - 1)
+}
+
+// json_decode parses a JSON document, objects become hashes in the order
+// their keys appear and numbers become integers unless they have a fraction
+// or exponent
+func jsonDecodeBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `json_decode` must be STRING, got %s", args[0].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+
+	value, err := decodeJSON(dec)
+	if err == nil {
+		if _, extra := dec.Token(); extra != io.EOF {
+			err = errors.New("unexpected data after the value")
+		}
+	}
+	if err != nil {
+		offset := dec.InputOffset()
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+			if offset > 0 && offset <= int64(len(str.Value)) && syntaxErr.Error() != "unexpected end of JSON input" {
+				// the offset counts the offending byte, point at it
+				offset--
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = errors.New("unexpected end of JSON input")
+		}
+		line, column := position(str.Value, offset)
+		return newError("json_decode: %s at line %d, column %d", err, line, column)
+	}
+
+	return value
+}
+
+func decodeJSON(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return nullObj, nil
+	case bool:
+		return nativeBoolToBoolObject(tok), nil
+	case string:
+		return &object.String{Value: tok}, nil
+	case json.Number:
+		if !strings.ContainsAny(tok.String(), ".eE") {
+			if value, err := tok.Int64(); err == nil {
+				return &object.Integer{Value: value}, nil
+			}
+		}
+		value, err := tok.Float64()
+		if err != nil {
+			return nil, errors.New("number out of range: " + tok.String())
+		}
+		return &object.Float{Value: value}, nil
+	case json.Delim:
+		if tok == '[' {
+			elements := []object.Object{}
+			for dec.More() {
+				el, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, el)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return &object.Array{Elements: elements}, nil
+		}
+
+		hash := object.NewHash()
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := &object.String{Value: keyTok.(string)}
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(key.HashKey(), object.HashPair{Key: key, Value: value})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return hash, nil
+	}
+
+	return nil, errors.New("unexpected token")
+}
+
+// position turns a byte offset into a 1 based line and column
+func position(input string, offset int64) (int, int) {
+	if offset > int64(len(input)) {
+		offset = int64(len(input))
+	}
+	before := input[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := len(before) - strings.LastIndex(before, "\n")
+	return line, column
+}
@@ -0,0 +1,205 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
+
+// maxMacroDepth bounds how deeply macros can expand into calls to macros
+const maxMacroDepth = 100
+
+// isQuoteCall reports whether a call is to quote or unquote, which are given
+// code rather than values
+func isQuoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && (ident.Value == "quote" || ident.Value == "unquote")
+}
+
+func evalQuoteCall(call *ast.CallExpression, env *object.Enviroment) object.Object {
+	name := call.Function.(*ast.Identifier).Value
+	if name == "unquote" {
+		return newError("unquote is only allowed inside quote")
+	}
+	if len(call.Arguments) != 1 {
+		return newError("wrong number of arguments to quote. got=%d, want=1", len(call.Arguments))
+	}
+	return quote(call.Arguments[0], env)
+}
+
+// quote turns code into a Quote, each unquote(x) in it is replaced by the
+// code for the value of x. The code is copied so the program isn't changed.
+func quote(node ast.Node, env *object.Enviroment) object.Object {
+	var err object.Object
+	node = ast.Apply(ast.Copy(node), func(c *ast.Cursor) bool {
+		arg, ok := ast.Unquoted(c.Node())
+		if !ok {
+			return true
+		}
+
+		value := Eval(arg, env)
+		if isError(value) {
+			err = value
+			return false
+		}
+		code := toCode(value, c.Node().(*ast.CallExpression).Token)
+		if code == nil {
+			err = newError("unquote: cannot turn %s into code", value.Type())
+			return false
+		}
+		c.Replace(code)
+		return false
+	}, nil)
+
+	if err != nil {
+		return err
+	}
+	return &object.Quote{Node: node}
+}
+
+// toCode is an expression that evaluates to obj, at the position of tok. It
+// is nil for values that can't be written in code, like functions.
+func toCode(obj object.Object, tok token.Token) ast.Expression {
+	at := func(typ token.Type, literal string) token.Token {
+		return token.Token{Type: typ, Literal: literal, Line: tok.Line, Column: tok.Column}
+	}
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{Token: at(token.INT, strconv.FormatInt(obj.Value, 10)), Value: obj.Value}
+	case *object.Float:
+		literal := strconv.FormatFloat(obj.Value, 'f', -1, 64)
+		if !strings.Contains(literal, ".") {
+			literal += ".0"
+		}
+		return &ast.FloatLiteral{Token: at(token.FLOAT, literal), Value: obj.Value}
+	case *object.String:
+		return &ast.StringLiteral{Token: at(token.STRING, obj.Value), Value: obj.Value}
+	case *object.Boolean:
+		if obj.Value {
+			return &ast.Boolean{Token: at(token.TRUE, "true"), Value: true}
+		}
+		return &ast.Boolean{Token: at(token.FALSE, "false"), Value: false}
+	case *object.Null:
+		return &ast.Null{Token: at(token.NULL, "null")}
+	case *object.Quote:
+		exp, _ := ast.Copy(obj.Node).(ast.Expression)
+		return exp
+	case *object.Array:
+		elements := make([]ast.Expression, len(obj.Elements))
+		for i, el := range obj.Elements {
+			if elements[i] = toCode(el, tok); elements[i] == nil {
+				return nil
+			}
+		}
+		return &ast.ArrayLiteral{Token: at(token.LBRACKET, "["), Elements: elements}
+	}
+	return nil
+}
+
+// DefineMacros removes the top level lets that bind a macro literal from a
+// program and defines the macros in env
+func DefineMacros(program *ast.Program, env *object.Enviroment) {
+	stmts := []ast.Statement{}
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			if lit, ok := let.Value.(*ast.MacroLiteral); ok {
+				env.Set(let.Name.Value, &object.Macro{Parameters: lit.Parameters, Body: lit.Body, Env: env})
+				continue
+			}
+		}
+		stmts = append(stmts, stmt)
+	}
+	program.Statements = stmts
+}
+
+// ExpandMacros replaces each call to a macro defined in env with the code
+// the macro returns, the macro is given its arguments as quoted code. It
+// returns an error when a macro fails and nil otherwise.
+func ExpandMacros(program *ast.Program, env *object.Enviroment) object.Object {
+	e := &expander{env: env}
+	e.expand(program)
+	return e.err
+}
+
+type expander struct {
+	env   *object.Enviroment
+	err   object.Object
+	depth int
+}
+
+func (e *expander) expand(node ast.Node) ast.Node {
+	return ast.Apply(node, nil, func(c *ast.Cursor) bool {
+		call, ok := c.Node().(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return true
+		}
+		obj, _ := e.env.Get(ident.Value)
+		macro, ok := obj.(*object.Macro)
+		if !ok {
+			return true
+		}
+
+		code := e.call(ident.Value, macro, call.Arguments)
+		if code == nil {
+			return false
+		}
+		c.Replace(code)
+		return true
+	})
+}
+
+// call runs a macro and expands the macros in the code it returns, it is
+// nil after setting e.err when that fails
+func (e *expander) call(name string, macro *object.Macro, args []ast.Expression) ast.Node {
+	if len(args) != len(macro.Parameters) {
+		e.err = newError("wrong number of arguments to macro %s. got=%d, want=%d",
+			name, len(args), len(macro.Parameters))
+		return nil
+	}
+	if e.depth >= maxMacroDepth {
+		e.err = newError("maximum macro expansion depth exceeded: %d", maxMacroDepth)
+		return nil
+	}
+
+	env := object.NewEnclosedEnviroment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: args[i]})
+	}
+	result := Eval(macro.Body, env)
+	if tailCall, ok := result.(*object.TailCall); ok {
+		result = applyFunction(tailCall.Function, tailCall.Arguments, env)
+	}
+	result = unwrapReturnValue(result)
+
+	if isError(result) {
+		e.err = newError("macro %s: %s", name, result.(*object.Error).Message)
+		return nil
+	}
+	quoted, ok := result.(*object.Quote)
+	if !ok {
+		e.err = newError("macro %s must return quoted code, got %s", name, typeName(result))
+		return nil
+	}
+
+	e.depth++
+	code := e.expand(ast.Copy(quoted.Node))
+	e.depth--
+	if e.err != nil {
+		return nil
+	}
+	return code
+}
+
+func typeName(obj object.Object) string {
+	if obj == nil {
+		return "nothing"
+	}
+	return string(obj.Type())
+}
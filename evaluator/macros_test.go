@@ -0,0 +1,174 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `QUOTE(5)`},
+		{`quote(foobar + 8)`, `QUOTE((foobar + 8))`},
+		{`quote(unquote(4 + 4))`, `QUOTE(8)`},
+		{`quote(8 + unquote(4 + 4))`, `QUOTE((8 + 8))`},
+		{`let x = 2; quote(unquote(x) * x)`, `QUOTE((2 * x))`},
+		{`quote(unquote(1.5) + unquote("s"))`, `QUOTE((1.5 + s))`},
+		{`quote(unquote(true == false))`, `QUOTE(false)`},
+		{`quote(unquote(null))`, `QUOTE(null)`},
+		{`quote(unquote([1, "a"]))`, `QUOTE([1, a])`},
+		{`let q = quote(4 + 4); quote(unquote(q) + 8)`, `QUOTE(((4 + 4) + 8))`},
+		{`let f = fn() { quote(a) }; f()`, `QUOTE(a)`},
+		{`quote(fn(x) { unquote(2) })`, `QUOTE(fn(x) 2)`},
+		{`quote(unquote(fn() { 1 }))`, `Error: unquote: cannot turn FUNCTION into code`},
+		{`quote(unquote(undefined))`, `Error: identifier not found: undefined`},
+		{`unquote(1)`, `Error: unquote is only allowed inside quote`},
+		{`quote(1, 2)`, `Error: wrong number of arguments to quote. got=2, want=1`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestQuoteLeavesProgram(t *testing.T) {
+	program := parser.New(lexer.New(`let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)`)).ParseProgram()
+	env := object.NewEnviroment()
+	if got := Eval(program, env).Inspect(); got != "QUOTE((2 + 1))" {
+		t.Errorf("wrong result. got=%q", got)
+	}
+}
+
+func testExpand(input string) (*ast.Program, object.Object) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnviroment()
+	DefineMacros(program, env)
+	return program, ExpandMacros(program, env)
+}
+
+func TestDefineMacros(t *testing.T) {
+	program := parser.New(lexer.New(`
+let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };`)).ParseProgram()
+	env := object.NewEnviroment()
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	for _, name := range []string{"number", "function"} {
+		if _, ok := env.Get(name); ok {
+			t.Errorf("%s should not be defined", name)
+		}
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 || macro.Parameters[0].Value != "x" || macro.Parameters[1].Value != "y" {
+		t.Errorf("wrong parameters. got=%v", macro.Parameters)
+	}
+	if macro.Body.String() != "(x + y)" {
+		t.Errorf("wrong body. got=%q", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let infix = macro() { quote(1 + 2) }; infix()`, `(1 + 2)`},
+		{`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)) }; reverse(2 + 2, 10 - 5)`, `((10 - 5) - (2 + 2))`},
+		{
+			`let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };
+unless(10 > 5, puts("not greater"), puts("greater"))`,
+			`if(!(10 > 5)) puts(not greater) else puts(greater)`,
+		},
+		{`let twice = macro(x) { quote([unquote(x), unquote(x)]) }; fn() { twice(a) }`, `fn() [a, a]`},
+		{
+			`let one = macro() { quote(1) }; let two = macro() { quote(one() + one()) }; two()`,
+			`(1 + 1)`,
+		},
+		{`let m = macro(a, b) { return b; a }; m(1, 2)`, `2`},
+		{`let m = macro(x) { x }; m(1)`, `1`},
+		{`let m = macro(x) { x }; let m = 1; m`, `let m = 1;m`},
+		{`let m = macro(x) { x }; m`, `m`},
+	}
+
+	for _, tt := range tests {
+		program, err := testExpand(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.input, err.Inspect())
+			continue
+		}
+		if got := program.String(); got != tt.expected {
+			t.Errorf("wrong expansion for %s. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = macro(x) { quote(x) }; m()`, `wrong number of arguments to macro m. got=0, want=1`},
+		{`let m = macro() { 1 }; m()`, `macro m must return quoted code, got INTEGER`},
+		{`let m = macro() { undefined }; m()`, `macro m: identifier not found: undefined`},
+		{`let m = macro() { quote(m()) }; m()`, `maximum macro expansion depth exceeded: 100`},
+		{`let m = macro() { quote(1) }; fn() { m(1) }`, `wrong number of arguments to macro m. got=1, want=0`},
+	}
+
+	for _, tt := range tests {
+		_, err := testExpand(tt.input)
+		if err == nil {
+			t.Errorf("%s: no error", tt.input)
+			continue
+		}
+		if err.(*object.Error).Message != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%q", tt.input, tt.expected, err.(*object.Error).Message)
+		}
+	}
+}
+
+func TestMacrosRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };
+unless(1 > 2, "yes", "no")`, "yes"},
+		{`let inc = macro(x) { quote(unquote(x) + 1) }; let f = fn(n) { inc(n) * 2 }; f(inc(1))`, "6"},
+		{`let m = macro() { quote(x) }; let f = fn(x) { m() }; f(7)`, "7"},
+		{`let f = fn() { let m = macro() { 1 }; m }; f()`, "Error: macro literals are only allowed in a let at the top level"},
+	}
+
+	for _, tt := range tests {
+		program, err := testExpand(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.input, err.Inspect())
+			continue
+		}
+		evaluated := Eval(program, object.NewEnviroment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
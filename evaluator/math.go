@@ -0,0 +1,367 @@
+package evaluator
+
+import (
+	"math"
+	"monkey/object"
+	"strconv"
+	"strings"
+)
+
+// constants are looked up after the builtins when an identifier is not bound
+var constants = map[string]object.Object{
+	"PI": &object.Float{Value: math.Pi},
+	"E":  &object.Float{Value: math.E},
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.IntegerObj || obj.Type() == object.FloatObj
+}
+
+// toFloat returns the value of an INTEGER or FLOAT as a float64
+func toFloat(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+// numberArgs checks that every argument is a number and returns them as floats
+func numberArgs(name string, args []object.Object) ([]float64, object.Object) {
+	values := make([]float64, len(args))
+	for i, arg := range args {
+		value, ok := toFloat(arg)
+		if !ok {
+			return nil, newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// floatToInteger converts a whole float result back to an integer
+func floatToInteger(value float64) object.Object {
+	if math.IsNaN(value) || value < math.MinInt64 || value >= math.MaxInt64 {
+		return newError("math range error: %s does not fit in an INTEGER", strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return &object.Integer{Value: int64(value)}
+}
+
+// unaryFloatBuiltin wraps a float function of one number, domain rejects
+// arguments the function is not defined for
+func unaryFloatBuiltin(name string, fn func(float64) float64, domain func(float64) bool) *object.Builtin {
+	return &object.Builtin{Fn: func(env *object.Enviroment, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		values, err := numberArgs(name, args)
+		if err != nil {
+			return err
+		}
+
+		if domain != nil && !domain(values[0]) {
+			return newError("math domain error: `%s` is not defined for %s", name, args[0].Inspect())
+		}
+
+		return &object.Float{Value: fn(values[0])}
+	}}
+}
+
+func absBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		if arg.Value == math.MinInt64 {
+			return newError("math range error: abs(%d) does not fit in an INTEGER", arg.Value)
+		}
+		if arg.Value < 0 {
+			return &object.Integer{Value: -arg.Value}
+		}
+		return arg
+	case *object.Float:
+		return &object.Float{Value: math.Abs(arg.Value)}
+	default:
+		return newError("argument to `abs` must be INTEGER or FLOAT, got %s", arg.Type())
+	}
+}
+
+// extremum picks the smallest or largest of its arguments, or of the
+// elements of a single array argument, keeping the winner's type
+func extremum(name string, args []object.Object, want int) object.Object {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*object.Array); ok {
+			args = arr.Elements
+		}
+	}
+	if len(args) == 0 {
+		return newError("`%s` needs at least one value", name)
+	}
+
+	best := args[0]
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+		}
+		if cmp, _ := compareObjects(arg, best); cmp == want {
+			best = arg
+		}
+	}
+
+	return best
+}
+
+func minBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	return extremum("min", args, -1)
+}
+
+func maxBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	return extremum("max", args, 1)
+}
+
+// pow keeps integers exact when both arguments are integers and the
+// exponent is not negative
+func powBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	base, baseOk := args[0].(*object.Integer)
+	exp, expOk := args[1].(*object.Integer)
+	if baseOk && expOk && exp.Value >= 0 {
+		result := int64(1)
+		b, e := base.Value, exp.Value
+		ok := true
+		for e > 0 && ok {
+			if e&1 == 1 {
+				result, ok = multiply(result, b)
+			}
+			if e >>= 1; e > 0 && ok {
+				b, ok = multiply(b, b)
+			}
+		}
+		if !ok {
+			return newError("math range error: pow(%d, %d) does not fit in an INTEGER", base.Value, exp.Value)
+		}
+		return &object.Integer{Value: result}
+	}
+
+	values, err := numberArgs("pow", args)
+	if err != nil {
+		return err
+	}
+	if values[0] == 0 && values[1] < 0 {
+		return newError("math domain error: zero to a negative power")
+	}
+
+	result := math.Pow(values[0], values[1])
+	if math.IsNaN(result) {
+		return newError("math domain error: pow(%s, %s)", args[0].Inspect(), args[1].Inspect())
+	}
+
+	return &object.Float{Value: result}
+}
+
+// roundingBuiltin applies a rounding function and returns an integer
+func roundingBuiltin(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{Fn: func(env *object.Enviroment, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		switch arg := args[0].(type) {
+		case *object.Integer:
+			return arg
+		case *object.Float:
+			return floatToInteger(fn(arg.Value))
+		default:
+			return newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+		}
+	}}
+}
+
+func clampBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3", len(args))
+	}
+	if _, err := numberArgs("clamp", args); err != nil {
+		return err
+	}
+
+	value, lo, hi := args[0], args[1], args[2]
+	if cmp, _ := compareObjects(lo, hi); cmp > 0 {
+		return newError("clamp lower bound %s is above upper bound %s", lo.Inspect(), hi.Inspect())
+	}
+	if cmp, _ := compareObjects(value, lo); cmp < 0 {
+		return lo
+	}
+	if cmp, _ := compareObjects(value, hi); cmp > 0 {
+		return hi
+	}
+
+	return value
+}
+
+func integerPair(name string, args []object.Object) (int64, int64, object.Object) {
+	if len(args) != 2 {
+		return 0, 0, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	a, ok := args[0].(*object.Integer)
+	if !ok {
+		return 0, 0, newError("argument to `%s` must be INTEGER, got %s", name, args[0].Type())
+	}
+	b, ok := args[1].(*object.Integer)
+	if !ok {
+		return 0, 0, newError("argument to `%s` must be INTEGER, got %s", name, args[1].Type())
+	}
+
+	return a.Value, b.Value, nil
+}
+
+// multiply is a * b, ok is false when the product doesn't fit in an int64
+func multiply(a, b int64) (product int64, ok bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product = a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}
+
+// magnitude is the absolute value of n, which fits in a uint64 even for
+// math.MinInt64
+func magnitude(n int64) uint64 {
+	if n < 0 {
+		return uint64(-n)
+	}
+	return uint64(n)
+}
+
+func gcd(a, b int64) uint64 {
+	x, y := magnitude(a), magnitude(b)
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return x
+}
+
+func gcdBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	a, b, err := integerPair("gcd", args)
+	if err != nil {
+		return err
+	}
+
+	result := gcd(a, b)
+	if result > math.MaxInt64 {
+		return newError("math range error: gcd(%d, %d) does not fit in an INTEGER", a, b)
+	}
+	return &object.Integer{Value: int64(result)}
+}
+
+func lcmBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	a, b, err := integerPair("lcm", args)
+	if err != nil {
+		return err
+	}
+	if a == 0 || b == 0 {
+		return &object.Integer{Value: 0}
+	}
+
+	quotient := magnitude(a) / gcd(a, b)
+	if quotient > math.MaxInt64/magnitude(b) {
+		return newError("math range error: lcm(%d, %d) does not fit in an INTEGER", a, b)
+	}
+	return &object.Integer{Value: int64(quotient * magnitude(b))}
+}
+
+func atan2Builtin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	values, err := numberArgs("atan2", args)
+	if err != nil {
+		return err
+	}
+
+	return &object.Float{Value: math.Atan2(values[0], values[1])}
+}
+
+// log is the natural logarithm, or the logarithm to the given base
+func logBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	values, err := numberArgs("log", args)
+	if err != nil {
+		return err
+	}
+
+	if values[0] <= 0 {
+		return newError("math domain error: `log` is not defined for %s", args[0].Inspect())
+	}
+	if len(values) == 1 {
+		return &object.Float{Value: math.Log(values[0])}
+	}
+
+	if values[1] <= 0 || values[1] == 1 {
+		return newError("math domain error: invalid logarithm base %s", args[1].Inspect())
+	}
+	return &object.Float{Value: math.Log(values[0]) / math.Log(values[1])}
+}
+
+// int converts floats, rounding toward zero, and strings of digits to integers
+func intBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		return floatToInteger(math.Trunc(arg.Value))
+	case *object.String:
+		value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+		if err != nil {
+			return newError("could not convert %q to INTEGER", arg.Value)
+		}
+		return &object.Integer{Value: value}
+	default:
+		return newError("argument to `int` not supported, got %s", arg.Type())
+	}
+}
+
+func floatBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return &object.Float{Value: float64(arg.Value)}
+	case *object.Float:
+		return arg
+	case *object.String:
+		value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return newError("could not convert %q to FLOAT", arg.Value)
+		}
+		return &object.Float{Value: value}
+	default:
+		return newError("argument to `float` not supported, got %s", arg.Type())
+	}
+}
+
+func nonNegative(x float64) bool { return x >= 0 }
+func unitRange(x float64) bool   { return x >= -1 && x <= 1 }
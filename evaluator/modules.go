@@ -0,0 +1,195 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/optimize"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/std"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Enviroment) object.Object {
+	path, ok := resolveImport(node.Path.Value, env)
+	if !ok {
+		return newError("module not found: %s", node.Path.Value)
+	}
+
+	module := loadModule(path, env.Runtime())
+	if isError(module) {
+		return module
+	}
+
+	env.Set(node.Alias.Value, module)
+	return nil
+}
+
+func evalExportStatement(node *ast.ExportStatement, env *object.Enviroment) object.Object {
+	val := Eval(node.Statement, env)
+	if isError(val) {
+		return val
+	}
+
+	if !env.Export(node.Statement.Name.Value) {
+		return newError("export is only allowed at the top level of a module")
+	}
+
+	return val
+}
+
+func evalMemberExpression(node *ast.MemberExpression, env *object.Enviroment) object.Object {
+	obj := Eval(node.Object, env)
+	if isError(obj) {
+		return obj
+	}
+
+	name := node.Property.Value
+
+	switch obj := obj.(type) {
+	case *object.Module:
+		val, ok := obj.Exports[name]
+		if !ok {
+			return newError("module %s has no export %s", obj.Name, name)
+		}
+		return val
+	case *object.Hash:
+		return evalHashIndexExpression(obj, &object.String{Value: name})
+	default:
+		return newError("member access not supported: %s.%s", obj.Type(), name)
+	}
+}
+
+// resolveImport finds the file for an import path. Paths starting with std/
+// are the embedded standard library, relative paths are looked up next to
+// the importing file first and then in each directory of the runtime's
+// search path. The .mky extension may be left off.
+func resolveImport(path string, env *object.Enviroment) (string, bool) {
+	if filepath.Ext(path) != ".mky" {
+		path += ".mky"
+	}
+
+	if strings.HasPrefix(path, std.Prefix) {
+		_, ok := std.Source(strings.TrimPrefix(path, std.Prefix))
+		return path, ok
+	}
+
+	if filepath.IsAbs(path) {
+		return path, isFile(path)
+	}
+
+	dirs := []string{"."}
+	if file := env.File(); file != "" {
+		dirs[0] = filepath.Dir(file)
+	}
+	dirs = append(dirs, env.Runtime().SearchPath...)
+
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, path)
+		if isFile(candidate) {
+			abs, err := filepath.Abs(candidate)
+			if err != nil {
+				return candidate, true
+			}
+			return abs, true
+		}
+	}
+
+	return "", false
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// loadModule evaluates a module in its own enviroment the first time it is
+// imported, later imports get the cached module
+func loadModule(path string, runtime *object.Runtime) object.Object {
+	if module, ok := runtime.Module(path); ok {
+		return module
+	}
+
+	if cycle, ok := runtime.BeginLoad(path); !ok {
+		return newError("import cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	module, err := evalModule(path, runtime)
+	if err != nil {
+		runtime.EndLoad(path, nil)
+		return err
+	}
+
+	runtime.EndLoad(path, module)
+	return module
+}
+
+// moduleSource reads a module, standard library modules are only read from
+// the binary when they are first imported
+func moduleSource(path string) (string, object.Object) {
+	if strings.HasPrefix(path, std.Prefix) {
+		source, _ := std.Source(strings.TrimPrefix(path, std.Prefix))
+		return source, nil
+	}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", newError("could not read module %s: %s", path, err)
+	}
+	return string(source), nil
+}
+
+func evalModule(path string, runtime *object.Runtime) (*object.Module, object.Object) {
+	source, err := moduleSource(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, newError("could not parse module %s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+	macros := object.NewModuleEnviroment(runtime, path)
+	DefineMacros(program, macros)
+	if err := ExpandMacros(program, macros); err != nil {
+		return nil, newError("could not expand macros in module %s: %s", path, err.(*object.Error).Message)
+	}
+	if err := resolveModule(path, program, runtime); err != nil {
+		return nil, err
+	}
+	optimize.Program(program)
+
+	env := object.NewModuleEnviroment(runtime, path)
+	if result := Eval(program, env); isError(result) {
+		return nil, result
+	}
+
+	exports := make(map[string]object.Object)
+	for _, name := range env.Exports() {
+		exports[name], _ = env.Get(name)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), ".mky")
+	return &object.Module{Name: name, Path: path, Exports: exports}, nil
+}
+
+// resolveModule checks the names in a module before it runs
+func resolveModule(path string, program *ast.Program, runtime *object.Runtime) object.Object {
+	errs := resolver.Resolve(program, func(name string) bool {
+		return Predeclared(runtime, name)
+	}).Errors
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return newError("could not resolve module %s: %s", path, strings.Join(msgs, "; "))
+}
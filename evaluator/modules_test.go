@@ -0,0 +1,130 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testEvalFile(t *testing.T, path string, runtime *object.Runtime) object.Object {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return Eval(program, object.NewModuleEnviroment(runtime, path))
+}
+
+func TestImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib/math.mky": `
+			let square = fn(x) { x * x };
+			export let cube = fn(x) { x * square(x) };
+			export let answer = 42;
+			let answer = answer + 1;`,
+		"lib/shared.mky": `export let items = [];`,
+		"lib/user.mky": `
+			import "math.mky" as m;
+			import "shared" as shared;
+			push(shared.items, "user");
+			export let twice = fn(x) { m.cube(x) * 2 };`,
+		"vendor/greet.mky": `export let hello = fn(name) { "hello " + name };`,
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/math.mky" as m; m.cube(3)`, "27"},
+		{`import "lib/math" as m; m.answer`, "43"},
+		{`import "lib/math"; math.answer`, "43"},
+		{`import "lib/math" as m; m.square(2)`, "Error: module math has no export square"},
+		{`import "lib/math" as m; m`, "module math"},
+		{`import "lib/user" as u; u.twice(2)`, "16"},
+		{`import "lib/user" as u; import "lib/shared" as s; import "lib/user" as again; s.items`, "[user]"},
+		{`import "greet" as g; g.hello("monkey")`, "hello monkey"},
+		{`import "missing" as x; 1`, "Error: module not found: missing"},
+		{`let f = fn() { export let x = 1; }; f()`, "Error: export is only allowed at the top level of a module"},
+		{`let h = {"a": 1}; h.a + 1`, "2"},
+		{`let h = {"a": 1}; h.b`, "null"},
+		{`5.a`, "Error: member access not supported: INTEGER.a"},
+	}
+
+	for _, tt := range tests {
+		main := filepath.Join(dir, "main.mky")
+		if err := ioutil.WriteFile(main, []byte(tt.input), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		runtime := object.NewRuntime()
+		runtime.SearchPath = []string{filepath.Join(dir, "vendor")}
+
+		evaluated := testEvalFile(t, main, runtime)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestImportCycle(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.mky":    `import "b" as b; export let a = 1;`,
+		"b.mky":    `import "a" as a; export let b = 2;`,
+		"main.mky": `import "a" as a; a.a`,
+	})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.mky"), object.NewRuntime())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	a, b := filepath.Join(dir, "a.mky"), filepath.Join(dir, "b.mky")
+	expected := "import cycle: " + a + " -> " + b + " -> " + a
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
+
+func TestImportUndefinedName(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"typo.mky": `export let f = fn() { if (false) { lenght([]) } };`,
+		"main.mky": `import "typo" as typo; typo.f()`,
+	})
+
+	evaluated := testEvalFile(t, filepath.Join(dir, "main.mky"), object.NewRuntime())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := "could not resolve module " + filepath.Join(dir, "typo.mky") + ": 1:36: undefined: lenght"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"monkey/std"
+	"testing"
+)
+
+func TestStdModulesLoad(t *testing.T) {
+	for _, name := range std.Names() {
+		evaluated := testEval(`import "std/` + name + `" as m; m`)
+		module, ok := evaluated.(*object.Module)
+		if !ok {
+			t.Errorf("std/%s did not load. got=%T (%+v)", name, evaluated, evaluated)
+			continue
+		}
+		if len(module.Exports) == 0 {
+			t.Errorf("std/%s exports nothing", name)
+		}
+	}
+}
+
+func TestStdLibrary(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "std/collections" as c; c.range(2, 6)`, "[2, 3, 4, 5]"},
+		{`import "std/collections" as c; c.sum([1, 2, 3])`, "6"},
+		{`import "std/collections" as c; c.product([2, 3, 4])`, "24"},
+		{`import "std/collections" as c; c.reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`import "std/collections" as c; c.take([1, 2, 3], 2)`, "[1, 2]"},
+		{`import "std/collections" as c; c.drop([1, 2, 3], 2)`, "[3]"},
+		{`import "std/collections" as c; c.chunk([1, 2, 3, 4, 5], 2)`, "[[1, 2], [3, 4], [5]]"},
+		{`import "std/collections" as c; c.chunk([1], 0)`, "Error: chunk size must be at least 1"},
+		{`import "std/collections" as c; c.flatten([[1], [2, [3]], 4])`, "[1, 2, [3], 4]"},
+		{`import "std/collections" as c; c.unique([1, 2, 1, 3, 2])`, "[1, 2, 3]"},
+		{`import "std/collections" as c; c.group_by(["a", "bb", "c"], len)`, "{1: [a, c], 2: [bb]}"},
+		{`import "std/collections" as c; c.count([1, 2, 3], fn(x) { x > 1 })`, "2"},
+		{`import "std/collections" as c; c.partition([1, 2, 3, 4], fn(x) { x % 2 == 0 })`, "[[2, 4], [1, 3]]"},
+		{`import "std/strings" as s; s.lines("a\nb")`, "[a, b]"},
+		{`import "std/strings" as s; s.title("hello monkey world")`, "Hello Monkey World"},
+		{`import "std/strings" as s; s.reverse("abc")`, "cba"},
+		{`import "std/strings" as s; s.is_blank("  ")`, "true"},
+		{`import "std/strings" as s; s.pad_left("7", 3, "0")`, "007"},
+		{`import "std/strings" as s; s.pad_right("ab", 4, ".")`, "ab.."},
+		{`import "std/math" as m; [m.is_even(4), m.is_odd(4)]`, "[true, false]"},
+		{`import "std/math" as m; [m.sign(-5), m.sign(0), m.sign(3)]`, "[-1, 0, 1]"},
+		{`import "std/math" as m; m.factorial(10)`, "3628800"},
+		{`import "std/math" as m; m.factorial(-1)`, "Error: factorial of a negative number"},
+		{`import "std/math" as m; m.fibonacci(10)`, "55"},
+		{`import "std/math" as m; filter([1, 2, 3, 4, 5, 9, 11], m.is_prime)`, "[2, 3, 5, 11]"},
+		{`import "std/functional" as f; f.compose(fn(x) { x + 1 }, fn(x) { x * 2 })(5)`, "11"},
+		{`import "std/functional" as f; f.pipe([fn(x) { x + 1 }, fn(x) { x * 2 }])(5)`, "12"},
+		{`import "std/functional" as f; f.partial(fn(a, b) { a - b }, 10)(3)`, "7"},
+		{`import "std/functional" as f; f.flip(fn(a, b) { a - b })(10, 3)`, "-7"},
+		{`import "std/functional" as f; filter([1, 2, 3], f.negate(fn(x) { x == 2 }))`, "[1, 3]"},
+		{`import "std/functional" as f; f.times(3, f.identity)`, "[0, 1, 2]"},
+		{`import "std/functional" as f; map([1, 2], f.constant(0))`, "[0, 0]"},
+		{`import "std/functional" as f;
+		  let calls = [];
+		  let slow = f.memoize(fn(x) { push(calls, x); x * x });
+		  [slow(3), slow(3), slow(4), len(calls)]`, "[9, 9, 16, 2]"},
+		{`import "std/assert" as a; a.equal([1, {"a": [2]}], [1, {"a": [2]}])`, "true"},
+		{`import "std/assert" as a; a.equal([1, 2], [1, 3])`, "Error: assertion failed: expected [1, 3], got [1, 2]"},
+		{`import "std/assert" as a; a.equal(1, "1")`, "Error: assertion failed: expected 1, got 1"},
+		{`import "std/assert" as a; a.not_equal({"a": 1}, {"a": 2})`, "true"},
+		{`import "std/assert" as a; a.ok(0, "zero is falsy"); 1`, "Error: assertion failed: zero is falsy"},
+		{`import "std/missing" as m; 1`, "Error: module not found: std/missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"strings"
+	"unicode/utf8"
+)
+
+// String positions and lengths are counted in characters, not bytes, to
+// match `len` and string indexing.
+
+// maxStringLength is the longest string repeat builds, in bytes
+const maxStringLength = 1 << 30
+
+// stringArgs checks that the first n arguments are strings and returns their values
+func stringArgs(name string, args []object.Object, n int) ([]string, object.Object) {
+	values := make([]string, n)
+	for i := 0; i < n; i++ {
+		str, ok := args[i].(*object.String)
+		if !ok {
+			return nil, newError("argument to `%s` must be STRING, got %s", name, args[i].Type())
+		}
+		values[i] = str.Value
+	}
+	return values, nil
+}
+
+func splitBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	values, err := stringArgs("split", args, len(args))
+	if err != nil {
+		return err
+	}
+
+	var parts []string
+	if len(values) == 1 {
+		parts = strings.Fields(values[0])
+	} else {
+		parts = strings.Split(values[0], values[1])
+	}
+
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func joinBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to `join` must be ARRAY, got %s", args[0].Type())
+	}
+
+	sep := ""
+	if len(args) == 2 {
+		sepObj, ok := args[1].(*object.String)
+		if !ok {
+			return newError("argument to `join` must be STRING, got %s", args[1].Type())
+		}
+		sep = sepObj.Value
+	}
+
+	parts := make([]string, len(arr.Elements))
+	for i, el := range arr.Elements {
+		parts[i] = el.Inspect()
+	}
+
+	return &object.String{Value: strings.Join(parts, sep)}
+}
+
+func trimBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	values, err := stringArgs("trim", args, len(args))
+	if err != nil {
+		return err
+	}
+
+	if len(values) == 1 {
+		return &object.String{Value: strings.TrimSpace(values[0])}
+	}
+	return &object.String{Value: strings.Trim(values[0], values[1])}
+}
+
+func upperBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	values, err := stringArgs("upper", args, 1)
+	if err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.ToUpper(values[0])}
+}
+
+func lowerBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	values, err := stringArgs("lower", args, 1)
+	if err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.ToLower(values[0])}
+}
+
+// contains also works on arrays, checking for an equal element
+func containsBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	if arr, ok := args[0].(*object.Array); ok {
+		for _, el := range arr.Elements {
+			if objectsEqual(el, args[1]) {
+				return trueObj
+			}
+		}
+		return falseObj
+	}
+
+	values, err := stringArgs("contains", args, 2)
+	if err != nil {
+		return err
+	}
+
+	return nativeBoolToBoolObject(strings.Contains(values[0], values[1]))
+}
+
+func startsWithBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	values, err := stringArgs("starts_with", args, 2)
+	if err != nil {
+		return err
+	}
+
+	return nativeBoolToBoolObject(strings.HasPrefix(values[0], values[1]))
+}
+
+func endsWithBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	values, err := stringArgs("ends_with", args, 2)
+	if err != nil {
+		return err
+	}
+
+	return nativeBoolToBoolObject(strings.HasSuffix(values[0], values[1]))
+}
+
+// index_of returns the position of the first match, or -1, it also works on arrays
+func indexOfBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	if arr, ok := args[0].(*object.Array); ok {
+		for i, el := range arr.Elements {
+			if objectsEqual(el, args[1]) {
+				return &object.Integer{Value: int64(i)}
+			}
+		}
+		return &object.Integer{Value: -1}
+	}
+
+	values, err := stringArgs("index_of", args, 2)
+	if err != nil {
+		return err
+	}
+
+	i := strings.Index(values[0], values[1])
+	if i < 0 {
+		return &object.Integer{Value: -1}
+	}
+
+	return &object.Integer{Value: int64(utf8.RuneCountInString(values[0][:i]))}
+}
+
+func replaceAllBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3", len(args))
+	}
+
+	values, err := stringArgs("replace_all", args, 3)
+	if err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.ReplaceAll(values[0], values[1], values[2])}
+}
+
+func repeatBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	values, err := stringArgs("repeat", args, 1)
+	if err != nil {
+		return err
+	}
+
+	count, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError("argument to `repeat` must be INTEGER, got %s", args[1].Type())
+	}
+	if count.Value < 0 {
+		return newError("repeat count must not be negative, got %d", count.Value)
+	}
+	if len(values[0]) != 0 && count.Value > maxStringLength/int64(len(values[0])) {
+		return newError("repeat result too long, got count %d for a string of length %d", count.Value, len(values[0]))
+	}
+
+	return &object.String{Value: strings.Repeat(values[0], int(count.Value))}
+}
+
+func charsBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	values, err := stringArgs("chars", args, 1)
+	if err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for _, ch := range values[0] {
+		elements = append(elements, &object.String{Value: string(ch)})
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// substr(s, start, length) returns up to length characters from start,
+// without a length it runs to the end of the string
+func substrBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+
+	values, err := stringArgs("substr", args, 1)
+	if err != nil {
+		return err
+	}
+	chars := []rune(values[0])
+
+	start, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError("argument to `substr` must be INTEGER, got %s", args[1].Type())
+	}
+	if start.Value < 0 || start.Value > int64(len(chars)) {
+		return newError("substr start out of range, got=%d, string length=%d", start.Value, len(chars))
+	}
+
+	end := int64(len(chars))
+	if len(args) == 3 {
+		length, ok := args[2].(*object.Integer)
+		if !ok {
+			return newError("argument to `substr` must be INTEGER, got %s", args[2].Type())
+		}
+		if length.Value < 0 {
+			return newError("substr length must not be negative, got %d", length.Value)
+		}
+		if length.Value < end-start.Value {
+			end = start.Value + length.Value
+		}
+	}
+
+	return &object.String{Value: string(chars[start.Value:end])}
+}
+
+// format replaces each {} in the template with the next argument
+func formatBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+	}
+
+	values, err := stringArgs("format", args, 1)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(values[0], "{}")
+	if len(parts)-1 != len(args)-1 {
+		return newError("format expects %d arguments, got=%d", len(parts)-1, len(args)-1)
+	}
+
+	var out strings.Builder
+	for i, part := range parts {
+		out.WriteString(part)
+		if i < len(args)-1 {
+			out.WriteString(args[i+1].Inspect())
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// objectsEqual compares values the way == does
+func objectsEqual(a, b object.Object) bool {
+	result := evalInfIxExpression("==", a, b)
+	return result == trueObj
+}
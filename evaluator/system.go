@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"os"
+)
+
+// env_get(name, default) returns an environment variable, or the default
+// (null when there isn't one) if it isn't set
+func envGetBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `env_get` must be STRING, got %s", args[0].Type())
+	}
+
+	if value, ok := os.LookupEnv(name.Value); ok {
+		return &object.String{Value: value}
+	}
+	if len(args) == 2 {
+		return args[1]
+	}
+	return nullObj
+}
+
+// env_set(name, value) sets an environment variable for the rest of the run
+// and any programs it starts
+func envSetBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	values, errObj := stringArgs("env_set", args, 2)
+	if errObj != nil {
+		return errObj
+	}
+
+	if err := os.Setenv(values[0], values[1]); err != nil {
+		return newError("env_set: %s", err)
+	}
+	return nullObj
+}
+
+// exit(code) ends the program with the given exit code, 0 by default
+func exitBuiltin(env *object.Enviroment, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+
+	code := int64(0)
+	if len(args) == 1 {
+		integer, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError("argument to `exit` must be INTEGER, got %s", args[0].Type())
+		}
+		code = integer.Value
+	}
+	if code < 0 || code > 255 {
+		return newError("exit code must be between 0 and 255, got %d", code)
+	}
+
+	env.Runtime().Exit(int(code))
+	return nullObj
+}
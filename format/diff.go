@@ -0,0 +1,118 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diff returns a unified diff turning a into b, with three lines of context,
+// or nothing when they are the same
+func Diff(name string, a, b []byte) []byte {
+	if string(a) == string(b) {
+		return nil
+	}
+	x := splitLines(string(a))
+	y := splitLines(string(b))
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		kind byte
+		text string
+		// the line's index in a and b, counting lines before it for inserts and deletes
+		i, j int
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, line{' ', x[i], i, j})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', x[i], i, j})
+			i++
+		default:
+			lines = append(lines, line{'+', y[j], i, j})
+			j++
+		}
+	}
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+	for start := 0; start < len(lines); {
+		if lines[start].kind == ' ' {
+			start++
+			continue
+		}
+
+		// a hunk runs until there are more than two contexts worth of unchanged lines
+		first := start - context
+		if first < 0 {
+			first = 0
+		}
+		end := start
+		for k := start; k < len(lines); k++ {
+			if lines[k].kind != ' ' {
+				end = k
+			} else if k-end > 2*context {
+				break
+			}
+		}
+		last := end + context
+		if last >= len(lines) {
+			last = len(lines) - 1
+		}
+
+		var removed, added int
+		for _, l := range lines[first : last+1] {
+			if l.kind != '+' {
+				removed++
+			}
+			if l.kind != '-' {
+				added++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(lines[first].i, removed), hunkRange(lines[first].j, added))
+		for _, l := range lines[first : last+1] {
+			out.WriteString(string(l.kind) + l.text + "\n")
+		}
+		start = last + 1
+	}
+	return []byte(out.String())
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
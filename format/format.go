@@ -0,0 +1,464 @@
+// Package format prints monkey source in its canonical style: four space
+// indents, one statement per line, spaces around binary operators and only
+// the parentheses that are needed. Comments are kept and at most one blank
+// line between statements is.
+package format
+
+import (
+	"errors"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+const (
+	indentWidth = 4
+	maxWidth    = 80
+)
+
+// Source formats a file, it fails if the source doesn't parse
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Diagnostics(); len(errs) != 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return nil, errors.New(strings.Join(msgs, "\n"))
+	}
+
+	pr := newPrinter(string(src), l.Comments())
+	return []byte(pr.program(program)), nil
+}
+
+// pos is a token's line and column
+type pos struct {
+	line   int
+	column int
+}
+
+func tokenPos(tok token.Token) pos {
+	return pos{line: tok.Line, column: tok.Column}
+}
+
+func (p pos) before(other pos) bool {
+	return p.line < other.line || p.line == other.line && p.column < other.column
+}
+
+type printer struct {
+	indent int
+
+	comments []token.Token
+	printed  []bool
+
+	// tokens are the source's tokens ending with EOF, to find where
+	// statements end
+	tokens []token.Token
+	// blockEnds maps the position of each { to its }
+	blockEnds map[pos]pos
+}
+
+func newPrinter(src string, comments []token.Token) *printer {
+	p := &printer{
+		comments:  comments,
+		printed:   make([]bool, len(comments)),
+		blockEnds: make(map[pos]pos),
+	}
+
+	var open []pos
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		p.tokens = append(p.tokens, tok)
+		switch tok.Type {
+		case token.LBRACE:
+			open = append(open, tokenPos(tok))
+		case token.RBRACE:
+			if len(open) > 0 {
+				p.blockEnds[open[len(open)-1]] = tokenPos(tok)
+				open = open[:len(open)-1]
+			}
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	return p
+}
+
+// lineBefore is the line of the last token before a position
+func (p *printer) lineBefore(at pos) int {
+	i := sort.Search(len(p.tokens), func(i int) bool {
+		return !tokenPos(p.tokens[i]).before(at)
+	})
+	if i == 0 {
+		return 0
+	}
+	return p.tokens[i-1].Line
+}
+
+// save and restore let a list be printed again in its broken form without
+// losing the comments printed the first time
+func (p *printer) save() []bool {
+	return append([]bool{}, p.printed...)
+}
+
+func (p *printer) restore(printed []bool) {
+	copy(p.printed, printed)
+}
+
+func (p *printer) indentation() string {
+	return strings.Repeat(" ", p.indent*indentWidth)
+}
+
+func (p *printer) program(program *ast.Program) string {
+	eof := tokenPos(p.tokens[len(p.tokens)-1])
+	return p.statements(program.Statements, pos{line: 1}, eof, false)
+}
+
+// statements prints the statements of a block or the program, along with the
+// comments between the open and close positions, one line each. The last
+// expression of a function body is its result and isn't followed by a semicolon.
+func (p *printer) statements(stmts []ast.Statement, open, close pos, function bool) string {
+	var b strings.Builder
+	prevLine := 0
+
+	separate := func(line int) {
+		if prevLine > 0 && line-prevLine > 1 {
+			b.WriteString("\n")
+		}
+	}
+	comments := func(from, to pos) {
+		for i, c := range p.comments {
+			at := tokenPos(c)
+			if p.printed[i] || !from.before(at) || !at.before(to) {
+				continue
+			}
+			p.printed[i] = true
+			separate(c.Line)
+			b.WriteString(p.indentation() + c.Literal + "\n")
+			prevLine = c.Line
+		}
+	}
+
+	for i, stmt := range stmts {
+		start := statementPos(stmt)
+		comments(open, start)
+		separate(start.line)
+
+		next := close
+		if i+1 < len(stmts) {
+			next = statementPos(stmts[i+1])
+		}
+
+		b.WriteString(p.indentation() + p.statement(stmt, function && i == len(stmts)-1))
+		endLine := p.lineBefore(next)
+		for j, c := range p.comments {
+			at := tokenPos(c)
+			if !p.printed[j] && c.Line == endLine && start.before(at) && at.before(next) {
+				p.printed[j] = true
+				b.WriteString(" " + c.Literal)
+			}
+		}
+		b.WriteString("\n")
+
+		prevLine = endLine
+		open = start
+	}
+	comments(open, close)
+
+	return b.String()
+}
+
+func statementPos(stmt ast.Statement) pos {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return tokenPos(stmt.Token)
+	case *ast.ReturnStatement:
+		return tokenPos(stmt.Token)
+	case *ast.ExpressionStatement:
+		return tokenPos(stmt.Token)
+	case *ast.ImportStatement:
+		return tokenPos(stmt.Token)
+	case *ast.ExportStatement:
+		return tokenPos(stmt.Token)
+	}
+	return pos{}
+}
+
+func (p *printer) statement(stmt ast.Statement, last bool) string {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		name := stmt.Name.Value
+		if stmt.Type != nil {
+			name += ": " + stmt.Type.Name
+		}
+		if value, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			return "let " + name + " = " + p.function(value, false) + ";"
+		}
+		return "let " + name + " = " + p.expression(stmt.Value, parser.LOWEST) + ";"
+	case *ast.ReturnStatement:
+		return "return " + p.expression(stmt.ReturnValue, parser.LOWEST) + ";"
+	case *ast.ExpressionStatement:
+		out := p.expression(stmt.Expression, parser.LOWEST)
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.WhileExpression:
+			return out
+		}
+		if last {
+			return out
+		}
+		return out + ";"
+	case *ast.ImportStatement:
+		out := "import " + quote(stmt.Path.Value)
+		// an alias the parser made up has the position of the path
+		if tokenPos(stmt.Alias.Token) != tokenPos(stmt.Path.Token) {
+			out += " as " + stmt.Alias.Value
+		}
+		return out + ";"
+	case *ast.ExportStatement:
+		return "export " + p.statement(stmt.Statement, false)
+	}
+	return stmt.String()
+}
+
+// precedence is how tightly an expression binds, an operand that binds less
+// tightly than its operator needs parentheses
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return operatorPrecedence(exp.Operator)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression:
+		return parser.INDEX
+	}
+	return parser.INDEX + 1
+}
+
+func operatorPrecedence(operator string) int {
+	switch operator {
+	case "==", "!=":
+		return parser.EQUALS
+	case "<", ">":
+		return parser.LESSGREATER
+	case "+", "-":
+		return parser.SUM
+	case "*", "/", "%":
+		return parser.PRODUCT
+	}
+	return parser.LOWEST
+}
+
+// expression prints an expression, in parentheses when it binds less tightly
+// than the given precedence
+func (p *printer) expression(exp ast.Expression, prec int) string {
+	out := p.bare(exp)
+	if precedence(exp) < prec {
+		return "(" + out + ")"
+	}
+	return out
+}
+
+func (p *printer) bare(exp ast.Expression) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Value
+	case *ast.IntegerLiteral:
+		return exp.Token.Literal
+	case *ast.FloatLiteral:
+		return exp.Token.Literal
+	case *ast.StringLiteral:
+		return quote(exp.Value)
+	case *ast.Boolean:
+		return exp.Token.Literal
+	case *ast.Null:
+		return "null"
+	case *ast.PrefixExpression:
+		return exp.Operator + p.expression(exp.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := operatorPrecedence(exp.Operator)
+		return p.expression(exp.Left, prec) + " " + exp.Operator + " " + p.expression(exp.Right, prec+1)
+	case *ast.CallExpression:
+		return p.expression(exp.Function, parser.CALL) + p.list("(", ")", len(exp.Arguments), func(i int) string {
+			return p.expression(exp.Arguments[i], parser.LOWEST)
+		})
+	case *ast.IndexExpression:
+		return p.expression(exp.Left, parser.INDEX) + "[" + p.expression(exp.Index, parser.LOWEST) + "]"
+	case *ast.SliceExpression:
+		out := p.expression(exp.Left, parser.INDEX) + "[" + p.optional(exp.Start) + ":" + p.optional(exp.End)
+		if exp.Step != nil {
+			out += ":" + p.optional(exp.Step)
+		}
+		return out + "]"
+	case *ast.MemberExpression:
+		return p.expression(exp.Object, parser.INDEX) + "." + exp.Property.Value
+	case *ast.ArrayLiteral:
+		return p.list("[", "]", len(exp.Elements), func(i int) string {
+			return p.expression(exp.Elements[i], parser.LOWEST)
+		})
+	case *ast.HashLiteral:
+		return p.list("{", "}", len(exp.Keys), func(i int) string {
+			key := exp.Keys[i]
+			return p.expression(key, parser.LOWEST) + ": " + p.expression(exp.Pairs[key], parser.LOWEST)
+		})
+	case *ast.FunctionLiteral:
+		return p.function(exp, true)
+	case *ast.MacroLiteral:
+		params := make([]string, len(exp.Parameters))
+		for i, param := range exp.Parameters {
+			params[i] = param.Value
+		}
+		return "macro(" + strings.Join(params, ", ") + ") " + p.block(exp.Body, true)
+	case *ast.IfExpression:
+		out := "if (" + p.expression(exp.Condition, parser.LOWEST) + ") " + p.block(exp.Consequence, false)
+		if exp.Alternative != nil {
+			out += " else " + p.block(exp.Alternative, false)
+		}
+		return out
+	case *ast.WhileExpression:
+		return "while (" + p.expression(exp.Test, parser.LOWEST) + ") " + p.block(exp.Body, false)
+	}
+	return exp.String()
+}
+
+func (p *printer) optional(exp ast.Expression) string {
+	if exp == nil {
+		return ""
+	}
+	return p.expression(exp, parser.LOWEST)
+}
+
+func (p *printer) blockRange(block *ast.BlockStatement) (pos, pos) {
+	open := tokenPos(block.Token)
+	close, ok := p.blockEnds[open]
+	if !ok {
+		close = tokenPos(p.tokens[len(p.tokens)-1])
+	}
+	return open, close
+}
+
+// function prints a function literal. With inline a body that is a single
+// short expression stays on one line, like fn(x) { x * 2 }, functions bound
+// by let always get a body on lines of their own.
+func (p *printer) function(exp *ast.FunctionLiteral, inline bool) string {
+	params := make([]string, len(exp.Parameters))
+	for i, param := range exp.Parameters {
+		params[i] = param.Value
+		if typ := exp.ParameterType(i); typ != nil {
+			params[i] += ": " + typ.Name
+		}
+	}
+	out := "fn(" + strings.Join(params, ", ") + ") "
+	if exp.ReturnType != nil {
+		out += "-> " + exp.ReturnType.Name + " "
+	}
+	if inline {
+		return out + p.functionBody(exp.Body)
+	}
+	return out + p.block(exp.Body, true)
+}
+
+func (p *printer) functionBody(block *ast.BlockStatement) string {
+	if len(block.Statements) == 1 {
+		stmt, ok := block.Statements[0].(*ast.ExpressionStatement)
+		open, close := p.blockRange(block)
+		if ok && !p.hasComments(open, close) {
+			printed := p.save()
+			out := "{ " + p.statement(stmt, true) + " }"
+			if !strings.Contains(out, "\n") && len(p.indentation())+len(out) <= maxWidth {
+				return out
+			}
+			p.restore(printed)
+		}
+	}
+	return p.block(block, true)
+}
+
+func (p *printer) hasComments(from, to pos) bool {
+	for _, c := range p.comments {
+		if at := tokenPos(c); from.before(at) && at.before(to) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) block(block *ast.BlockStatement, function bool) string {
+	open, close := p.blockRange(block)
+
+	p.indent++
+	body := p.statements(block.Statements, open, close, function)
+	p.indent--
+
+	if body == "" {
+		return "{}"
+	}
+	return "{\n" + body + p.indentation() + "}"
+}
+
+// list prints items between brackets on one line when they fit, or when only
+// the last item spans lines like a function passed as the last argument.
+// Otherwise every item gets a line of its own.
+func (p *printer) list(open, close string, n int, item func(i int) string) string {
+	printed := p.save()
+
+	items := make([]string, n)
+	hugged := true
+	for i := range items {
+		items[i] = item(i)
+		if i < n-1 && strings.Contains(items[i], "\n") {
+			hugged = false
+		}
+	}
+	flat := open + strings.Join(items, ", ") + close
+	firstLine := strings.SplitN(flat, "\n", 2)[0]
+	if hugged && len(p.indentation())+len(firstLine) <= maxWidth {
+		return flat
+	}
+
+	p.restore(printed)
+	p.indent++
+	var b strings.Builder
+	b.WriteString(open + "\n")
+	for i := 0; i < n; i++ {
+		b.WriteString(p.indentation() + item(i))
+		if i < n-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	p.indent--
+	b.WriteString(p.indentation() + close)
+	return b.String()
+}
+
+var escapes = map[rune]string{
+	'\n': `\n`,
+	'\t': `\t`,
+	'\r': `\r`,
+	'"':  `\"`,
+	'\\': `\\`,
+}
+
+// quote writes a string literal using the lexer's escapes
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteString(`"`)
+	for _, r := range s {
+		if escaped, ok := escapes[r]; ok {
+			b.WriteString(escaped)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString(`"`)
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package format
+
+import (
+	"monkey/std"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"let x = (1 + 2) * 3;", "let x = (1 + 2) * 3;\n"},
+		{"let x = ((1 + 2)) + (3);", "let x = 1 + 2 + 3;\n"},
+		{"let x = 1 - (2 - 3);", "let x = 1 - (2 - 3);\n"},
+		{"let x = -(a + b);", "let x = -(a + b);\n"},
+		{"puts(\"a\\n\\\"b\\\"\")", "puts(\"a\\n\\\"b\\\"\");\n"},
+		{"let a = [1,2,3][1:];", "let a = [1, 2, 3][1:];\n"},
+		{"let h = {\"a\":1};", "let h = {\"a\": 1};\n"},
+		{"import \"std/math\"", "import \"std/math\";\n"},
+		{"import \"std/math\" as m", "import \"std/math\" as m;\n"},
+		{"export let x=1", "export let x = 1;\n"},
+		{"let f = fn(x){x*2}", "let f = fn(x) {\n    x * 2\n};\n"},
+		{"map(a, fn(x){x*2})", "map(a, fn(x) { x * 2 });\n"},
+		{
+			"let f = fn(x){\nlet y = x;\n\n\n\nreturn y}",
+			"let f = fn(x) {\n    let y = x;\n\n    return y;\n};\n",
+		},
+		{
+			"if(x<1){puts(x)}else{puts(1)}",
+			"if (x < 1) {\n    puts(x);\n} else {\n    puts(1);\n}\n",
+		},
+		{"while(true){}", "while (true) {}\n"},
+		{"let x:int=1", "let x: int = 1;\n"},
+		{"let f = fn(a:int,b)->bool{true}", "let f = fn(a: int, b) -> bool {\n    true\n};\n"},
+		{
+			"let m = macro(a,b){quote(unquote(a)+unquote(b))}",
+			"let m = macro(a, b) {\n    quote(unquote(a) + unquote(b))\n};\n",
+		},
+		{
+			"// top\nlet x = 1; // one\n\n// two\nlet y = 2;\n// end",
+			"// top\nlet x = 1; // one\n\n// two\nlet y = 2;\n// end\n",
+		},
+		{
+			"let f = fn() {\n    // nothing\n};",
+			"let f = fn() {\n    // nothing\n};\n",
+		},
+		{
+			"let a = [\"aaaaaaaaaaaaaaaa\", \"bbbbbbbbbbbbbbbb\", \"cccccccccccccccc\", \"dddddddddddddddd\", \"eeeeeeeeeeeeeeee\"];",
+			"let a = [\n    \"aaaaaaaaaaaaaaaa\",\n    \"bbbbbbbbbbbbbbbb\",\n    \"cccccccccccccccc\",\n    \"dddddddddddddddd\",\n    \"eeeeeeeeeeeeeeee\"\n];\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", tt.input, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("%q: wrong output.\nexpected=%q\ngot=%q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	_, err := Source([]byte("let = 5;"))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if !strings.HasPrefix(err.Error(), "1:5: ") {
+		t.Errorf("error has no position, got=%q", err)
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	for _, name := range std.Names() {
+		src, _ := std.Source(name)
+		once, err := Source([]byte(src))
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		twice, err := Source(once)
+		if err != nil {
+			t.Errorf("%s: formatted source doesn't parse: %s", name, err)
+			continue
+		}
+		if string(once) != string(twice) {
+			t.Errorf("%s: formatting is not idempotent\n%s", name, Diff(name, once, twice))
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	b := "a\nb\nc\nd\nE\nf\ng\nh\n"
+
+	expected := `--- x.mky
++++ x.mky
+@@ -2,7 +2,7 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+`
+	if got := string(Diff("x.mky", []byte(a), []byte(b))); got != expected {
+		t.Errorf("wrong diff.\nexpected=%q\ngot=%q", expected, got)
+	}
+
+	if got := Diff("x.mky", []byte(a), []byte(a)); len(got) != 0 {
+		t.Errorf("expected no diff for equal input, got=%q", got)
+	}
+}
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"monkey/token"
+	"strings"
 )
 
 // Lexer is a lexer
@@ -10,16 +11,28 @@ type Lexer struct {
 	position     int
 	readPosition int
 	ch           byte
+
+	// line and column are the position of ch
+	line   int
+	column int
+
+	comments []token.Token
 }
 
 // New => creates new lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -38,10 +51,17 @@ func (l *Lexer) peekChar() byte {
 
 //NextToken returns the next token and reads the next char
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
 
+	line, column := l.line, l.column
+	tok := l.readToken()
+	tok.Line, tok.Column = line, column
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -69,7 +89,12 @@ func (l *Lexer) NextToken() token.Token {
 	case '+':
 		tok = token.New(token.PLUS, l.ch)
 	case '-':
-		tok = token.New(token.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "->"}
+		} else {
+			tok = token.New(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -93,6 +118,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = token.New(token.COMMA, l.ch)
 	case ':':
 		tok = token.New(token.COLON, l.ch)
+	case '.':
+		tok = token.New(token.DOT, l.ch)
 	case 0:
 		tok = token.Token{Type: token.EOF, Literal: ""}
 	case '"':
@@ -103,8 +130,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		}
 		tok = token.New(token.ILLEGAL, l.ch)
@@ -113,15 +139,41 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// Position is the line and column just after the last token returned by
+// NextToken
+func (l *Lexer) Position() (line, column int) {
+	return l.line, l.column
+}
+
+//Comments returns the comments skipped so far, in the order they appear
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// skipWhitespace also skips // comments, which run to the end of the line
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar()
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			comment := token.Token{Type: token.COMMENT, Line: l.line, Column: l.column}
+			position := l.position
+			for l.ch != '\n' && l.ch != 0 {
+				l.readChar()
+			}
+			comment.Literal = strings.TrimRight(l.input[position:l.position], " \t\r")
+			l.comments = append(l.comments, comment)
+		default:
+			return
+		}
 	}
 }
 
+// readIdentifier reads letters, then letters and digits
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
@@ -131,25 +183,52 @@ func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer, or a float when the digits are followed by a
+// dot and more digits
+func (l *Lexer) readNumber() (string, token.Type) {
 	position := l.position
+	tokenType := token.Type(token.INT)
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+	return l.input[position:l.position], tokenType
 }
 
 func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// readString reads up to the closing quote, handling the escapes \n, \t, \r, \" and \\
 func (l *Lexer) readString() string {
-	position := l.position + 1
+	var out []byte
 	for {
 		l.readChar()
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
+		if l.ch == '\\' {
+			if escaped, ok := escapes[l.peekChar()]; ok {
+				l.readChar()
+				out = append(out, escaped)
+				continue
+			}
+		}
+		out = append(out, l.ch)
 	}
-	return l.input[position:l.position]
+	return string(out)
+}
+
+var escapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
 }
@@ -0,0 +1,124 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/resolver"
+	"sort"
+)
+
+// The names of a document come from the resolver, analysis adds what an
+// editor needs on top: where identifiers and scopes are in the source.
+
+// use is an identifier in the source and the declaration it names, decl is
+// nil for builtins and names that aren't defined
+type use struct {
+	ident *ast.Identifier
+	decl  *resolver.Declaration
+	// def is the index in decl.Defs of the definition the use sees
+	def int
+}
+
+// extent is where a scope's source starts and ends
+type extent struct {
+	start pos
+	end   pos
+}
+
+type analysis struct {
+	names *resolver.Result
+	// uses are sorted by position
+	uses    []use
+	extents map[*resolver.Scope]extent
+	// imports maps the alias of each import to its statement
+	imports map[*ast.Identifier]*ast.ImportStatement
+}
+
+func analyse(program *ast.Program, ends map[pos]pos) *analysis {
+	a := &analysis{
+		names:   resolver.Resolve(program, func(string) bool { return true }),
+		extents: make(map[*resolver.Scope]extent),
+		imports: make(map[*ast.Identifier]*ast.ImportStatement),
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if imp, ok := node.(*ast.ImportStatement); ok && imp.Alias != nil && imp.Path != nil {
+			a.imports[imp.Alias] = imp
+		}
+		return true
+	})
+
+	for ident, binding := range a.names.Bindings {
+		if _, implicit := a.implicitImport(ident); !implicit {
+			a.uses = append(a.uses, use{ident: ident, decl: binding.Declaration, def: binding.Def})
+		}
+	}
+	for _, ident := range a.names.Unresolved {
+		a.uses = append(a.uses, use{ident: ident})
+	}
+	sort.Slice(a.uses, func(i, j int) bool {
+		return tokenPos(a.uses[i].ident.Token).before(tokenPos(a.uses[j].ident.Token))
+	})
+
+	a.extents[a.names.Root] = extent{start: pos{line: 1, column: 1}, end: pos{line: int(^uint(0) >> 1)}}
+	var measure func(s *resolver.Scope)
+	measure = func(s *resolver.Scope) {
+		for _, child := range s.Children {
+			var e extent
+			switch node := child.Node.(type) {
+			case *ast.FunctionLiteral:
+				e.start = tokenPos(node.Token)
+				if node.Body != nil {
+					e.end = ends[tokenPos(node.Body.Token)]
+				}
+			case *ast.MacroLiteral:
+				e.start = tokenPos(node.Token)
+				if node.Body != nil {
+					e.end = ends[tokenPos(node.Body.Token)]
+				}
+			}
+			a.extents[child] = e
+			measure(child)
+		}
+	}
+	measure(a.names.Root)
+	return a
+}
+
+// implicitImport is the import statement an identifier is the alias of when
+// the import has no `as`
+func (a *analysis) implicitImport(ident *ast.Identifier) (*ast.ImportStatement, bool) {
+	imp, ok := a.imports[ident]
+	// the parser gives an implicit alias the position of the path
+	return imp, ok && tokenPos(imp.Alias.Token) == tokenPos(imp.Path.Token)
+}
+
+// useAt finds the identifier covering a position, the position just after
+// the identifier counts so a cursor at the end of a word finds it
+func (a *analysis) useAt(p pos) (use, bool) {
+	for _, u := range a.uses {
+		start := tokenPos(u.ident.Token)
+		if start.line == p.line && start.column <= p.column && p.column <= start.column+len(u.ident.Value) {
+			return u, true
+		}
+	}
+	return use{}, false
+}
+
+// scopeAt is the innermost scope containing a position
+func (a *analysis) scopeAt(p pos) *resolver.Scope {
+	s := a.names.Root
+	for {
+		var inner *resolver.Scope
+		for _, child := range s.Children {
+			e := a.extents[child]
+			if e.start.before(p) && p.before(e.end) {
+				inner = child
+				break
+			}
+		}
+		if inner == nil {
+			return s
+		}
+		s = inner
+	}
+}
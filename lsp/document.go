@@ -0,0 +1,176 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is an open file, parsed and analysed on every change
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	program     *ast.Program
+	diagnostics []parser.Error
+	analysis    *analysis
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	d.diagnostics = p.Diagnostics()
+	d.analysis = analyse(d.program, blockEnds(text))
+	return d
+}
+
+// pos is a position in the source, the line and byte column count from 1
+// like a token's
+type pos struct {
+	line   int
+	column int
+}
+
+func tokenPos(tok token.Token) pos {
+	return pos{line: tok.Line, column: tok.Column}
+}
+
+func (p pos) before(other pos) bool {
+	return p.line < other.line || p.line == other.line && p.column < other.column
+}
+
+// blockEnds maps the position of each { to the position of its }, so the
+// extent of a function body is known
+func blockEnds(text string) map[pos]pos {
+	ends := make(map[pos]pos)
+	var open []pos
+
+	l := lexer.New(text)
+	tok := l.NextToken()
+	for ; tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE:
+			open = append(open, tokenPos(tok))
+		case token.RBRACE:
+			if len(open) > 0 {
+				ends[open[len(open)-1]] = tokenPos(tok)
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	// blocks left open run to the end of the file
+	for _, start := range open {
+		ends[start] = tokenPos(tok)
+	}
+	return ends
+}
+
+// position converts a source position to the protocol's, a column inside a
+// character counts as its start
+func (d *document) position(p pos) Position {
+	if p.line < 1 || p.line > len(d.lines) {
+		return Position{Line: p.line - 1}
+	}
+	line := d.lines[p.line-1]
+	column := p.column - 1
+	if column > len(line) {
+		column = len(line)
+	}
+	for column > 0 && column < len(line) && !utf8.RuneStart(line[column]) {
+		column--
+	}
+	return Position{Line: p.line - 1, Character: utf16Len(line[:column])}
+}
+
+// nextRune is the position after the character at p, the lexer reports
+// some errors at a byte inside a character
+func (d *document) nextRune(p pos) pos {
+	if p.line < 1 || p.line > len(d.lines) {
+		return pos{line: p.line, column: p.column + 1}
+	}
+	line := d.lines[p.line-1]
+	// the byte after the one at p
+	i := p.column
+	for i < len(line) && !utf8.RuneStart(line[i]) {
+		i++
+	}
+	return pos{line: p.line, column: i + 1}
+}
+
+// pos converts a protocol position to a source position
+func (d *document) pos(p Position) pos {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return pos{line: p.Line + 1, column: 1}
+	}
+
+	line := d.lines[p.Line]
+	units := 0
+	for i, r := range line {
+		if units >= p.Character {
+			return pos{line: p.Line + 1, column: i + 1}
+		}
+		units += utf16RuneLen(r)
+	}
+	return pos{line: p.Line + 1, column: len(line) + 1}
+}
+
+// identRange is the range an identifier covers
+func (d *document) identRange(ident *ast.Identifier) Range {
+	start := tokenPos(ident.Token)
+	end := pos{line: start.line, column: start.column + len(ident.Value)}
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+// linePrefix is the text of the line up to a position
+func (d *document) linePrefix(p pos) string {
+	if p.line < 1 || p.line > len(d.lines) {
+		return ""
+	}
+	line := d.lines[p.line-1]
+	if p.column-1 > len(line) {
+		return line
+	}
+	return line[:p.column-1]
+}
+
+// stringEnd is the position just after the closing quote of the string
+// literal starting at p
+func (d *document) stringEnd(p pos) pos {
+	for line := p.line; line <= len(d.lines); line++ {
+		text := d.lines[line-1]
+		i := 0
+		if line == p.line {
+			i = p.column
+		}
+		for ; i < len(text); i++ {
+			switch text[i] {
+			case '\\':
+				i++
+			case '"':
+				return pos{line: line, column: i + 2}
+			}
+		}
+	}
+	return p
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
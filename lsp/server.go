@@ -0,0 +1,490 @@
+// Package lsp is a language server for monkey, speaking the language server
+// protocol over a reader and writer, normally stdin and stdout
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/completion"
+	"monkey/evaluator"
+	"monkey/resolver"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// Server holds the open documents of one client
+type Server struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string]*document
+
+	shutdown bool
+}
+
+// NewServer creates a server reading requests from in and writing to out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*document),
+	}
+}
+
+// Serve handles messages until the client sends exit or closes the input
+func Serve(in io.Reader, out io.Writer) error {
+	return NewServer(in, out).Run()
+}
+
+// Run handles messages until the client sends exit or closes the input
+func (s *Server) Run() error {
+	for {
+		msg, err := s.readMessage()
+		if err == io.EOF {
+			return nil
+		}
+		if respErr, ok := err.(*ResponseError); ok {
+			// the body wasn't JSON, the request can't be answered by id
+			s.write(&message{ID: &nullID, Error: respErr})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit before shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			// notifications have no response
+			continue
+		}
+
+		resp := &message{ID: msg.ID}
+		if err != nil {
+			respErr, ok := err.(*ResponseError)
+			if !ok {
+				respErr = &ResponseError{Code: codeRequestFailed, Message: err.Error()}
+			}
+			resp.Error = respErr
+		} else if resp.Result, err = json.Marshal(result); err != nil {
+			return err
+		}
+		if err := s.write(resp); err != nil {
+			return err
+		}
+	}
+}
+
+var nullID = json.RawMessage("null")
+
+func (s *Server) readMessage() (*message, error) {
+	return readMessage(s.in)
+}
+
+func (s *Server) write(msg *message) error {
+	return writeMessage(s.out, msg)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(&message{Method: method, Params: body})
+}
+
+func (s *Server) handle(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return s.initialize()
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics",
+			PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/definition":
+		return s.positionRequest(msg, s.definition)
+	case "textDocument/hover":
+		return s.positionRequest(msg, s.hover)
+	case "textDocument/completion":
+		return s.positionRequest(msg, s.completion)
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(doc), nil
+	case "textDocument/rename":
+		var params RenameParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.rename(doc, doc.pos(params.Position), params.NewName)
+	}
+
+	if msg.ID == nil || strings.HasPrefix(msg.Method, "$/") {
+		return nil, nil
+	}
+	return nil, &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+func unmarshal(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) positionRequest(msg *message, handler func(*document, pos) (interface{}, error)) (interface{}, error) {
+	var params TextDocumentPositionParams
+	if err := unmarshal(msg.Params, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return handler(doc, doc.pos(params.Position))
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &ResponseError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+	return doc, nil
+}
+
+func (s *Server) initialize() (interface{}, error) {
+	return InitializeResult{
+		ServerInfo: ServerInfo{Name: "monkey"},
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:       1,
+			DefinitionProvider:     true,
+			HoverProvider:          true,
+			DocumentSymbolProvider: true,
+			RenameProvider:         true,
+			CompletionProvider:     CompletionOptions{TriggerCharacters: []string{"."}},
+		},
+	}, nil
+}
+
+// update parses a document's new text and publishes its parser errors
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, err := range doc.diagnostics {
+		start := pos{line: err.Line, column: err.Column}
+		end := doc.nextRune(start)
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: doc.position(start), End: doc.position(end)},
+			Severity: SeverityError,
+			Source:   "monkey",
+			Message:  err.Message,
+		})
+	}
+	return s.notify("textDocument/publishDiagnostics",
+		PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+func (s *Server) definition(doc *document, p pos) (interface{}, error) {
+	u, ok := doc.analysis.useAt(p)
+	if !ok || u.decl == nil {
+		return nil, nil
+	}
+	return Location{URI: doc.uri, Range: doc.defRange(u.decl.Defs[u.def])}, nil
+}
+
+// defRange is the range of a definition, the path of an import without an
+// alias
+func (d *document) defRange(ident *ast.Identifier) Range {
+	if imp, implicit := d.analysis.implicitImport(ident); implicit {
+		return d.stringRange(imp.Path)
+	}
+	return d.identRange(ident)
+}
+
+func (d *document) stringRange(lit *ast.StringLiteral) Range {
+	start := tokenPos(lit.Token)
+	return Range{Start: d.position(start), End: d.position(d.stringEnd(start))}
+}
+
+func (s *Server) hover(doc *document, p pos) (interface{}, error) {
+	u, ok := doc.analysis.useAt(p)
+	if !ok {
+		return nil, nil
+	}
+
+	var text string
+	if u.decl != nil {
+		text = doc.analysis.describe(u.decl, u.def)
+	} else if _, ok := evaluator.Builtin(u.ident.Value); ok {
+		text = "builtin function " + u.ident.Value
+	} else if constant, ok := evaluator.Constant(u.ident.Value); ok {
+		text = "let " + u.ident.Value + " = " + constant.Inspect()
+	} else {
+		return nil, nil
+	}
+
+	r := doc.identRange(u.ident)
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + text + "\n```"},
+		Range:    &r,
+	}, nil
+}
+
+// describe is the hover text of a definition: a function's signature, the
+// value of other bindings or the import a module came from
+func (a *analysis) describe(decl *resolver.Declaration, def int) string {
+	switch decl.Kind {
+	case resolver.Param:
+		fn, ok := decl.Scope.Node.(*ast.FunctionLiteral)
+		if !ok {
+			return decl.Name + ", a parameter of a macro"
+		}
+		return decl.Name + ", a parameter of " + signature("fn", fn)
+	case resolver.Import:
+		return a.imports[decl.Defs[def]].String()
+	}
+
+	if fn, ok := decl.Values[def].(*ast.FunctionLiteral); ok {
+		return signature("fn "+decl.Name, fn)
+	}
+	value := "null"
+	if decl.Values[def] != nil {
+		value = decl.Values[def].String()
+	}
+	if runes := []rune(value); len(runes) > 80 {
+		value = string(runes[:77]) + "..."
+	}
+	return "let " + decl.Name + " = " + value
+}
+
+func signature(name string, fn *ast.FunctionLiteral) string {
+	params := make([]string, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = param.Value
+		if typ := fn.ParameterType(i); typ != nil {
+			params[i] += ": " + typ.Name
+		}
+	}
+	out := name + "(" + strings.Join(params, ", ") + ")"
+	if fn.ReturnType != nil {
+		out += " -> " + fn.ReturnType.Name
+	}
+	return out
+}
+
+func (s *Server) documentSymbols(doc *document) []DocumentSymbol {
+	return doc.symbols(doc.analysis.names.Root)
+}
+
+// symbols lists the lets and imports of a scope, functions list their own
+func (d *document) symbols(sc *resolver.Scope) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, decl := range sc.Declarations {
+		if decl.Kind == resolver.Param {
+			continue
+		}
+
+		r := d.defRange(decl.Defs[0])
+		ds := DocumentSymbol{Name: decl.Name, Kind: SymbolVariable, Range: r, SelectionRange: r}
+
+		switch value := decl.Values[0].(type) {
+		case *ast.FunctionLiteral:
+			ds.Kind = SymbolFunction
+			ds.Detail = signature("fn", value)
+			if fnScope, ok := d.analysis.names.Scopes[value]; ok {
+				ds.Range.End = d.position(d.analysis.extents[fnScope].end)
+				ds.Children = d.symbols(fnScope)
+			}
+		}
+		if decl.Kind == resolver.Import {
+			ds.Kind = SymbolModule
+			ds.Detail = d.analysis.imports[decl.Defs[0]].Path.Value
+		}
+		symbols = append(symbols, ds)
+	}
+	return symbols
+}
+
+func (s *Server) completion(doc *document, p pos) (interface{}, error) {
+	line := doc.linePrefix(p)
+	result := completion.Complete(line, nil)
+	prefix := line[result.Start:]
+	afterDot := result.Start > 0 && line[result.Start-1] == '.'
+
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	if !afterDot && (prefix == "" || !isDigit(prefix[0])) {
+		for sc := doc.analysis.scopeAt(p); sc != nil; sc = sc.Parent {
+			for _, decl := range sc.Declarations {
+				if seen[decl.Name] || !strings.HasPrefix(decl.Name, prefix) {
+					continue
+				}
+				seen[decl.Name] = true
+				items = append(items, doc.analysis.symbolItem(decl))
+			}
+		}
+	}
+
+	for _, candidate := range result.Candidates {
+		if seen[candidate.Text] {
+			continue
+		}
+		seen[candidate.Text] = true
+		items = append(items, CompletionItem{
+			Label:  candidate.Text,
+			Kind:   completionKinds[candidate.Kind],
+			Detail: candidate.Kind.String(),
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return CompletionList{Items: items}, nil
+}
+
+var completionKinds = map[completion.Kind]int{
+	completion.Variable: CompletionVariable,
+	completion.Builtin:  CompletionFunction,
+	completion.Constant: CompletionConstant,
+	completion.Keyword:  CompletionKeyword,
+	completion.Key:      CompletionField,
+	completion.Member:   CompletionField,
+}
+
+func (a *analysis) symbolItem(decl *resolver.Declaration) CompletionItem {
+	def := len(decl.Defs) - 1
+	item := CompletionItem{Label: decl.Name, Kind: CompletionVariable, Detail: a.describe(decl, def)}
+	if decl.Kind == resolver.Import {
+		item.Kind = CompletionModule
+	} else if _, ok := decl.Values[def].(*ast.FunctionLiteral); ok {
+		item.Kind = CompletionFunction
+	}
+	return item
+}
+
+func (s *Server) rename(doc *document, p pos, newName string) (interface{}, error) {
+	if !isIdentifier(newName) || token.LookupIdent(newName) != token.IDENT {
+		return nil, &ResponseError{Code: codeRequestFailed, Message: fmt.Sprintf("%q is not a valid name", newName)}
+	}
+
+	u, ok := doc.analysis.useAt(p)
+	if !ok || u.decl == nil {
+		return nil, &ResponseError{Code: codeRequestFailed, Message: "no binding to rename here"}
+	}
+	if ident := doc.analysis.capture(u.decl, newName); ident != nil {
+		return nil, &ResponseError{Code: codeRequestFailed, Message: fmt.Sprintf(
+			"renaming to %s would change what %s on line %d refers to", newName, ident.Value, ident.Token.Line)}
+	}
+
+	var edits []TextEdit
+	for _, def := range u.decl.Defs {
+		if imp, implicit := doc.analysis.implicitImport(def); implicit {
+			// add the alias the import didn't spell out
+			end := doc.position(doc.stringEnd(tokenPos(imp.Path.Token)))
+			edits = append(edits, TextEdit{Range: Range{Start: end, End: end}, NewText: " as " + newName})
+			continue
+		}
+		edits = append(edits, TextEdit{Range: doc.identRange(def), NewText: newName})
+	}
+	for _, ref := range u.decl.Refs {
+		edits = append(edits, TextEdit{Range: doc.identRange(ref), NewText: newName})
+	}
+
+	sort.Slice(edits, func(i, j int) bool {
+		a, b := edits[i].Range.Start, edits[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
+	return WorkspaceEdit{Changes: map[string][]TextEdit{doc.uri: edits}}, nil
+}
+
+// capture finds an identifier a rename would bind to a different name:
+// newName already names something else where decl is bound or used, or decl
+// would hide a declaration of newName from one of its uses
+func (a *analysis) capture(decl *resolver.Declaration, newName string) *ast.Identifier {
+	idents := append(append([]*ast.Identifier{}, decl.Defs...), decl.Refs...)
+	for _, ident := range idents {
+		b := a.names.Bindings[ident]
+		if other, depth := b.Scope.Lookup(newName); other != nil && other != decl && depth <= b.Depth {
+			return ident
+		}
+	}
+
+	for _, u := range a.uses {
+		if u.ident.Value != newName || u.decl == decl {
+			continue
+		}
+		// builtins and undefined names are found after every scope
+		s, depth := a.scopeAt(tokenPos(u.ident.Token)), int(^uint(0)>>1)
+		if u.decl != nil {
+			b := a.names.Bindings[u.ident]
+			s, depth = b.Scope, b.Depth
+		}
+		for d := 0; s != nil && d <= depth; d, s = d+1, s.Parent {
+			if s == decl.Scope {
+				return u.ident
+			}
+		}
+	}
+	return nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || isDigit(ch)) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
@@ -0,0 +1,408 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testClient talks to a server running in the same process over pipes
+type testClient struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Reader
+	nextID int
+	done   chan error
+}
+
+func newTestClient(t *testing.T) *testClient {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &testClient{t: t, in: clientOut, out: bufio.NewReader(clientIn), done: make(chan error, 1)}
+	go func() {
+		c.done <- Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	var result InitializeResult
+	c.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result)
+	c.notify("initialized", struct{}{})
+	return c
+}
+
+func (c *testClient) send(msg *message) {
+	if err := writeMessage(c.in, msg); err != nil {
+		c.t.Fatalf("write failed: %s", err)
+	}
+}
+
+func (c *testClient) read() *message {
+	msg, err := readMessage(c.out)
+	if err != nil {
+		c.t.Fatalf("read failed: %s", err)
+	}
+	return msg
+}
+
+func (c *testClient) notify(method string, params interface{}) {
+	body, _ := json.Marshal(params)
+	c.send(&message{Method: method, Params: body})
+}
+
+// request sends a request and decodes its result, it returns the error of a
+// failed request
+func (c *testClient) request(method string, params interface{}, result interface{}) *ResponseError {
+	c.nextID++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.nextID))))
+	body, _ := json.Marshal(params)
+	c.send(&message{ID: &id, Method: method, Params: body})
+
+	msg := c.read()
+	if msg.ID == nil || string(*msg.ID) != string(id) {
+		c.t.Fatalf("expected the response to %s, got %+v", method, msg)
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if err := json.Unmarshal(msg.Result, result); err != nil {
+		c.t.Fatalf("bad result for %s: %s", method, err)
+	}
+	return nil
+}
+
+func mustMarshal(v interface{}) []byte {
+	body, _ := json.Marshal(v)
+	return body
+}
+
+// diagnostics reads the diagnostics published after a document changes
+func (c *testClient) diagnostics() PublishDiagnosticsParams {
+	msg := c.read()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %+v", msg)
+	}
+	var params PublishDiagnosticsParams
+	json.Unmarshal(msg.Params, &params)
+	return params
+}
+
+func (c *testClient) open(uri, text string) PublishDiagnosticsParams {
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Text: text},
+	})
+	return c.diagnostics()
+}
+
+func (c *testClient) close() {
+	var result interface{}
+	if err := c.request("shutdown", nil, &result); err != nil {
+		c.t.Fatalf("shutdown failed: %s", err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Fatalf("server failed: %s", err)
+	}
+}
+
+func at(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func rng(line, start, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+const source = `import "std/math";
+import "lib/util" as util;
+let scale = 2;
+let apply = fn(value, factor) {
+  let scaled = value * factor * scale;
+  let scaled = scaled + 1;
+  helper(scaled)
+};
+let helper = fn(x) { math.sign(x) };
+let s = "😀"; let t = s;
+`
+
+func TestInitialize(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+
+	var result InitializeResult
+	c.request("initialize", struct{}{}, &result)
+	caps := result.Capabilities
+	if caps.TextDocumentSync != 1 || !caps.DefinitionProvider || !caps.HoverProvider ||
+		!caps.DocumentSymbolProvider || !caps.RenameProvider {
+		t.Errorf("missing capabilities: %+v", caps)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+
+	published := c.open("file:///a.mky", "let x = 1;\nlet = 2;")
+	expected := []Diagnostic{
+		{Range: rng(1, 4, 5), Severity: SeverityError, Source: "monkey", Message: "expected next token to be IDENT, got ="},
+		{Range: rng(1, 4, 5), Severity: SeverityError, Source: "monkey", Message: "no prefix parse function for = found"},
+	}
+	if published.URI != "file:///a.mky" || !reflect.DeepEqual(published.Diagnostics, expected) {
+		t.Errorf("wrong diagnostics. expected=%+v, got=%+v", expected, published)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: "file:///a.mky"},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = 1;"}},
+	})
+	if published := c.diagnostics(); len(published.Diagnostics) != 0 {
+		t.Errorf("expected the diagnostics to be cleared, got=%+v", published.Diagnostics)
+	}
+
+	// the lexer reports each byte of a character it doesn't know
+	published = c.open("file:///b.mky", "let 🐵 = 3;")
+	illegal := Diagnostic{Range: rng(0, 4, 6), Severity: SeverityError, Source: "monkey", Message: "no prefix parse function for ILLEGAL found"}
+	expected = []Diagnostic{
+		{Range: rng(0, 4, 6), Severity: SeverityError, Source: "monkey", Message: "expected next token to be IDENT, got ILLEGAL"},
+		illegal, illegal, illegal, illegal,
+		{Range: rng(0, 7, 8), Severity: SeverityError, Source: "monkey", Message: "no prefix parse function for = found"},
+	}
+	if !reflect.DeepEqual(published.Diagnostics, expected) {
+		t.Errorf("wrong diagnostics inside a character. expected=%+v, got=%+v", expected, published.Diagnostics)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.mky"}})
+	c.diagnostics()
+	var hover Hover
+	if err := c.request("textDocument/hover", at("file:///a.mky", 0, 4), &hover); err == nil {
+		t.Errorf("expected an error for a closed document")
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	uri := "file:///src.mky"
+	c.open(uri, source)
+
+	tests := []struct {
+		line, character int
+		expected        *Range
+	}{
+		// scale in apply's body, a top level let
+		{4, 32, &Range{Start: Position{2, 4}, End: Position{2, 9}}},
+		// value, a parameter
+		{4, 15, &Range{Start: Position{3, 15}, End: Position{3, 20}}},
+		// scaled in `scaled + 1` is the first let
+		{5, 15, &Range{Start: Position{4, 6}, End: Position{4, 12}}},
+		// helper is declared after apply
+		{6, 2, &Range{Start: Position{8, 4}, End: Position{8, 10}}},
+		// math has no alias, the import path is its definition
+		{8, 22, &Range{Start: Position{0, 7}, End: Position{0, 17}}},
+		// after the emoji, which is two UTF-16 code units
+		{9, 22, &Range{Start: Position{9, 4}, End: Position{9, 5}}},
+		// a definition goes to itself
+		{2, 6, &Range{Start: Position{2, 4}, End: Position{2, 9}}},
+		// builtins and literals have no definition
+		{8, 28, nil},
+		{2, 12, nil},
+	}
+
+	for _, tt := range tests {
+		var loc *Location
+		if err := c.request("textDocument/definition", at(uri, tt.line, tt.character), &loc); err != nil {
+			t.Fatalf("definition failed: %s", err)
+		}
+		if tt.expected == nil {
+			if loc != nil {
+				t.Errorf("expected no definition at %d:%d, got=%+v", tt.line, tt.character, loc)
+			}
+			continue
+		}
+		if loc == nil || loc.URI != uri || loc.Range != *tt.expected {
+			t.Errorf("wrong definition at %d:%d. expected=%+v, got=%+v", tt.line, tt.character, tt.expected, loc)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	uri := "file:///src.mky"
+	c.open(uri, "len(\"x\") + PI;\n"+source)
+
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{4, 8, "fn apply(value, factor)"},
+		{3, 5, "let scale = 2"},
+		{5, 16, "value, a parameter of fn(value, factor)"},
+		{2, 23, `import "lib/util" as util;`},
+		{0, 1, "builtin function len"},
+		{0, 12, "let PI = 3.141592653589793"},
+		{10, 22, "let s = 😀"},
+	}
+
+	for _, tt := range tests {
+		var hover *Hover
+		if err := c.request("textDocument/hover", at(uri, tt.line, tt.character), &hover); err != nil {
+			t.Fatalf("hover failed: %s", err)
+		}
+		expected := "```monkey\n" + tt.expected + "\n```"
+		if hover == nil || hover.Contents.Value != expected {
+			t.Errorf("wrong hover at %d:%d. expected=%q, got=%+v", tt.line, tt.character, expected, hover)
+		}
+	}
+
+	var hover *Hover
+	c.request("textDocument/hover", at(uri, 3, 12), &hover)
+	if hover != nil {
+		t.Errorf("expected no hover over a literal, got=%+v", hover)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	uri := "file:///src.mky"
+	c.open(uri, source)
+
+	var symbols []DocumentSymbol
+	c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+
+	var got []string
+	var describe func(prefix string, symbols []DocumentSymbol)
+	describe = func(prefix string, symbols []DocumentSymbol) {
+		for _, sym := range symbols {
+			got = append(got, prefix+sym.Name+":"+string(rune('0'+sym.Kind/10))+string(rune('0'+sym.Kind%10)))
+			describe(prefix+sym.Name+".", sym.Children)
+		}
+	}
+	describe("", symbols)
+
+	expected := "math:02 util:02 scale:13 apply:12 apply.scaled:13 helper:12 s:13 t:13"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("wrong symbols. expected=%q, got=%q", expected, strings.Join(got, " "))
+	}
+	if symbols[3].Range.End != (Position{7, 0}) || symbols[3].Detail != "fn(value, factor)" {
+		t.Errorf("wrong function symbol: %+v", symbols[3])
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	uri := "file:///src.mky"
+	c.open(uri, source+"sc\nle")
+
+	labels := func(line, character int) string {
+		var list CompletionList
+		c.request("textDocument/completion", at(uri, line, character), &list)
+		var out []string
+		for _, item := range list.Items {
+			out = append(out, item.Label)
+		}
+		return strings.Join(out, " ")
+	}
+
+	// inside apply its parameters and lets are visible
+	if got := labels(5, 17); got != "scale scaled" {
+		t.Errorf("wrong completions in apply. got=%q", got)
+	}
+	if got := labels(4, 17); got != "value values" {
+		t.Errorf("wrong parameter completions. got=%q", got)
+	}
+	if got := labels(10, 2); got != "scale" {
+		t.Errorf("wrong top level completions. got=%q", got)
+	}
+	if got := labels(11, 2); got != "len let" {
+		t.Errorf("wrong keyword completions. got=%q", got)
+	}
+	if got := labels(8, 26); got != "" {
+		t.Errorf("expected no completions after a dot. got=%q", got)
+	}
+}
+
+func TestRename(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	uri := "file:///src.mky"
+	c.open(uri, source)
+
+	rename := func(line, character int, newName string) ([]TextEdit, *ResponseError) {
+		var edit WorkspaceEdit
+		err := c.request("textDocument/rename", RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: line, Character: character},
+			NewName:      newName,
+		}, &edit)
+		return edit.Changes[uri], err
+	}
+
+	edits, err := rename(4, 8, "result")
+	if err != nil {
+		t.Fatalf("rename failed: %s", err)
+	}
+	expected := []TextEdit{
+		{Range: rng(4, 6, 12), NewText: "result"},
+		{Range: rng(5, 6, 12), NewText: "result"},
+		{Range: rng(5, 15, 21), NewText: "result"},
+		{Range: rng(6, 9, 15), NewText: "result"},
+	}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("wrong edits. expected=%+v, got=%+v", expected, edits)
+	}
+
+	edits, _ = rename(8, 22, "m")
+	expected = []TextEdit{
+		{Range: rng(0, 17, 17), NewText: " as m"},
+		{Range: rng(8, 21, 25), NewText: "m"},
+	}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("wrong edits for an import. expected=%+v, got=%+v", expected, edits)
+	}
+
+	if _, err := rename(4, 8, "let"); err == nil {
+		t.Errorf("expected renaming to a keyword to fail")
+	}
+	if _, err := rename(8, 28, "size"); err == nil {
+		t.Errorf("expected renaming a builtin to fail")
+	}
+
+	captures := []struct {
+		line, character int
+		newName         string
+	}{
+		{4, 8, "value"},
+		{4, 8, "scale"},
+		{4, 8, "helper"},
+		{8, 16, "math"},
+		{9, 18, "s"},
+	}
+	for _, tt := range captures {
+		if _, err := rename(tt.line, tt.character, tt.newName); err == nil {
+			t.Errorf("expected renaming at %d:%d to %s to fail", tt.line, tt.character, tt.newName)
+		}
+	}
+	if _, err := rename(8, 16, "scale"); err != nil {
+		t.Errorf("expected renaming x to scale to work, got %s", err)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+
+	var result interface{}
+	err := c.request("workspace/unknown", struct{}{}, &result)
+	if err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got=%v", err)
+	}
+}
@@ -1,29 +1,102 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"monkey/ast"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
+	"monkey/optimize"
 	"monkey/parser"
 	"monkey/repl"
+	"monkey/resolver"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 )
 
+var seed = flag.Int64("seed", 0, "seed the random number generator so runs can be reproduced")
+var allowFS fsRoots
+
+func init() {
+	flag.Var(&allowFS, "allow-fs", "let scripts use the file system, `--allow-fs=dirs` limits them to directories separated by '"+
+		string(os.PathListSeparator)+"', on its own it allows the current directory")
+}
+
+// fsRoots is the value of --allow-fs, it can be given on its own like a
+// boolean flag or with a list of directories
+type fsRoots []string
+
+func (r *fsRoots) String() string {
+	return strings.Join(*r, string(os.PathListSeparator))
+}
+
+func (r *fsRoots) Set(value string) error {
+	if value == "true" {
+		value = "."
+	}
+	*r = append(*r, filepath.SplitList(value)...)
+	return nil
+}
+
+func (r *fsRoots) IsBoolFlag() bool {
+	return true
+}
+
 func main() {
-	if len(os.Args) == 1 {
-		startRepl()
-	} else if len(os.Args) == 2 {
-		runFile(os.Args[1])
+	flag.Parse()
+	if command, ok := commands[flag.Arg(0)]; ok {
+		os.Exit(command(flag.Args()[1:]))
+	}
+
+	runtime, err := newRuntime()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
+	if flag.NArg() == 0 {
+		runtime.Globals["ARGS"] = scriptArgs(nil)
+		startRepl(runtime)
+	} else {
+		runtime.Globals["ARGS"] = scriptArgs(flag.Args()[1:])
+		os.Exit(runFile(flag.Arg(0), runtime))
+	}
+}
+
+// scriptArgs turns the arguments after the script name into the ARGS array
+func scriptArgs(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
 }
 
-func startRepl() {
+// newRuntime creates the interpreter state configured by the command line flags
+func newRuntime() (*object.Runtime, error) {
+	runtime := object.NewRuntime()
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			runtime.Seed(*seed)
+		case "allow-fs":
+			if len(allowFS) == 0 {
+				err = fmt.Errorf("--allow-fs needs at least one directory")
+				return
+			}
+			err = runtime.AllowFS(allowFS...)
+		}
+	})
+	return runtime, err
+}
+
+func startRepl(runtime *object.Runtime) {
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -31,38 +104,63 @@ func startRepl() {
 
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, runtime)
 }
 
-func runFile(file string) {
+// runFile runs a script and returns the exit code for the process
+func runFile(file string, runtime *object.Runtime) int {
 	if !fileExists(file) {
-		fmt.Println("File not found")
-		return
+		fmt.Fprintln(os.Stderr, "File not found")
+		return 1
 	}
 	if !checkExt(file) {
-		fmt.Println("File type not supported")
-		return
+		fmt.Fprintln(os.Stderr, "File type not supported")
+		return 1
 	}
 	dat, err := ioutil.ReadFile(file)
-	check(err)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
 
-	env := object.NewEnviroment()
 	l := lexer.New(string(dat))
 	p := parser.New(l)
 
 	program := p.ParseProgram()
 	if len(p.Errors()) != 0 {
-		printParserErrors(os.Stdout, p.Errors())
-		return
+		printParserErrors(os.Stderr, p.Errors())
+		return 1
 	}
-
-	evaluator.Eval(program, env)
+	return runProgram(file, program, runtime)
 }
 
-func check(e error) {
-	if e != nil {
-		panic(e)
+// runProgram expands macros in a parsed script then resolves, optimizes and
+// runs it, file is where its imports are found from
+func runProgram(file string, program *ast.Program, runtime *object.Runtime) int {
+	macros := object.NewModuleEnviroment(runtime, file)
+	evaluator.DefineMacros(program, macros)
+	if err := evaluator.ExpandMacros(program, macros); err != nil {
+		fmt.Fprintln(os.Stderr, err.Inspect())
+		return 1
+	}
+
+	resolved := resolver.Resolve(program, func(name string) bool {
+		return evaluator.Predeclared(runtime, name)
+	})
+	if len(resolved.Errors) != 0 {
+		for _, err := range resolved.Errors {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", file, err)
+		}
+		return 1
+	}
+	optimize.Program(program)
+
+	env := object.NewModuleEnviroment(runtime, file)
+	if result, ok := evaluator.Eval(program, env).(*object.Error); ok {
+		fmt.Fprintln(os.Stderr, result.Inspect())
+		return 1
 	}
+	return 0
 }
 
 func fileExists(filename string) bool {
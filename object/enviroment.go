@@ -1,22 +1,34 @@
 package object
 
+import "sort"
+
 //NewEnviroment creates a new enviroment
 func NewEnviroment() *Enviroment {
+	return NewModuleEnviroment(NewRuntime(), "")
+}
+
+//NewModuleEnviroment creates the top level enviroment for a module loaded from file
+func NewModuleEnviroment(runtime *Runtime, file string) *Enviroment {
 	s := make(map[string]Object)
-	return &Enviroment{store: s}
+	return &Enviroment{store: s, runtime: runtime, file: file}
 }
 
 //NewEnclosedEnviroment creates a new enviroment with a pointer to the given Enviroment
 func NewEnclosedEnviroment(outer *Enviroment) *Enviroment {
-	env := NewEnviroment()
+	env := NewModuleEnviroment(outer.runtime, "")
 	env.outer = outer
 	return env
 }
 
 // Enviroment is a container for the vairables
 type Enviroment struct {
-	store map[string]Object
-	outer *Enviroment
+	store   map[string]Object
+	outer   *Enviroment
+	runtime *Runtime
+
+	// file and exports are only set on the top level enviroment of a module
+	file    string
+	exports []string
 }
 
 // Get returns a variable object from its name
@@ -33,3 +45,52 @@ func (e *Enviroment) Set(name string, obj Object) Object {
 	e.store[name] = obj
 	return obj
 }
+
+//Names returns the names bound directly in this enviroment, sorted
+func (e *Enviroment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Outer returns the enclosing enviroment, nil for the top level of a module
+func (e *Enviroment) Outer() *Enviroment {
+	return e.outer
+}
+
+//Runtime returns the interpreter state shared by this enviroment
+func (e *Enviroment) Runtime() *Runtime {
+	return e.runtime
+}
+
+//File is the path of the module this enviroment belongs to, empty if it was not loaded from a file
+func (e *Enviroment) File() string {
+	for env := e; env != nil; env = env.outer {
+		if env.outer == nil {
+			return env.file
+		}
+	}
+	return ""
+}
+
+//Export marks a top level binding as exported, it reports false for an enclosed enviroment
+func (e *Enviroment) Export(name string) bool {
+	if e.outer != nil {
+		return false
+	}
+	for _, exported := range e.exports {
+		if exported == name {
+			return true
+		}
+	}
+	e.exports = append(e.exports, name)
+	return true
+}
+
+//Exports returns the exported bindings in the order they were declared
+func (e *Enviroment) Exports() []string {
+	return e.exports
+}
@@ -4,28 +4,36 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"monkey/ast"
+	"strconv"
 	"strings"
 )
 
 const (
 	IntegerObj     = "INTEGER"
+	FloatObj       = "FLOAT"
 	StringObj      = "STRING"
 	BooleanObj     = "BOOLEAN"
 	NullObj        = "NULL"
 	ReturnValueObj = "RETURN_VALUE"
+	TailCallObj    = "TAIL_CALL"
 	ErrorObj       = "ERROR"
 	FunctionObj    = "FUNCTION"
 	BuiltinObj     = "BUILTIN"
 	ArrayObj       = "ARRAY"
 	HashObj        = "HASH"
+	ModuleObj      = "MODULE"
+	QuoteObj       = "QUOTE"
+	MacroObj       = "MACRO"
 )
 
 //ObjectType is an enum that represents the object type
 type ObjectType string
 
-//BuiltinFunction is for the functions that come with the monkey language
-type BuiltinFunction func(args ...Object) Object
+//BuiltinFunction is for the functions that come with the monkey language, env
+//is the enviroment it was called from and gives access to the interpreter's Runtime
+type BuiltinFunction func(env *Enviroment, args ...Object) Object
 
 //Object is the base object in monkey
 type Object interface {
@@ -63,6 +71,29 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+//Float is the floating point number object
+type Float struct {
+	Value float64
+}
+
+// Type gets the ObjectType
+func (f *Float) Type() ObjectType { return FloatObj }
+
+//Inspect gets the string representation, whole numbers keep a trailing .0
+//so they are not mistaken for integers
+func (f *Float) Inspect() string {
+	out := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(out, ".eIN") {
+		out += ".0"
+	}
+	return out
+}
+
+//HashKey gets a unique value for this object
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 //String is the string primative
 type String struct {
 	Value string
@@ -125,6 +156,25 @@ func (rv *ReturnValue) Type() ObjectType { return ReturnValueObj }
 //Inspect gets the string representation
 func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 
+//TailCall is a call in tail position, it is returned to applyFunction
+//instead of being evaluated so the call does not grow the stack
+type TailCall struct {
+	Function  Object
+	Arguments []Object
+}
+
+// Type gets the ObjectType
+func (tc *TailCall) Type() ObjectType { return TailCallObj }
+
+//Inspect gets the string representation
+func (tc *TailCall) Inspect() string {
+	args := []string{}
+	for _, a := range tc.Arguments {
+		args = append(args, a.Inspect())
+	}
+	return "tail call(" + strings.Join(args, ", ") + ")"
+}
+
 //Error is an user error
 type Error struct {
 	Message string
@@ -206,9 +256,53 @@ type HashPair struct {
 	Value Object
 }
 
-//Hash is a hashmap
+//Hash is a hashmap, it remembers the order keys were first inserted in
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+//NewHash creates an empty hash
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+//Get returns the pair stored under the key
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	pair, ok := h.Pairs[key]
+	return pair, ok
+}
+
+//Set stores a pair, a new key goes to the end while an existing key keeps its place
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+//Delete removes the key and reports whether it was present
+func (h *Hash) Delete(key HashKey) bool {
+	if _, ok := h.Pairs[key]; !ok {
+		return false
+	}
+	delete(h.Pairs, key)
+	for i, k := range h.Keys {
+		if k == key {
+			h.Keys = append(h.Keys[:i:i], h.Keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+//Ordered returns the pairs in insertion order
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Keys))
+	for _, key := range h.Keys {
+		pairs = append(pairs, h.Pairs[key])
+	}
+	return pairs
 }
 
 // Type gets the ObjectType
@@ -219,7 +313,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Ordered() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Value.Inspect()))
 	}
@@ -230,3 +324,48 @@ func (h *Hash) Inspect() string {
 
 	return out.String()
 }
+
+//Module is an imported file, holding the values it exported
+type Module struct {
+	Name    string
+	Path    string
+	Exports map[string]Object
+}
+
+// Type gets the ObjectType
+func (m *Module) Type() ObjectType { return ModuleObj }
+
+//Inspect gets the string representation
+func (m *Module) Inspect() string { return "module " + m.Name }
+
+//Quote is code that was quoted instead of evaluated, it is what macros
+//return
+type Quote struct {
+	Node ast.Node
+}
+
+// Type gets the ObjectType
+func (q *Quote) Type() ObjectType { return QuoteObj }
+
+//Inspect gets the string representation
+func (q *Quote) Inspect() string { return "QUOTE(" + q.Node.String() + ")" }
+
+//Macro is a macro, it is called with its arguments quoted before the
+//program runs
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Enviroment
+}
+
+// Type gets the ObjectType
+func (m *Macro) Type() ObjectType { return MacroObj }
+
+//Inspect gets the string representation
+func (m *Macro) Inspect() string {
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+	return "macro(" + strings.Join(params, ", ") + ") {\n" + m.Body.String() + "\n}"
+}
@@ -58,3 +58,58 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	keys := []Object{
+		&String{Value: "z"},
+		&Integer{Value: 1},
+		&Boolean{Value: true},
+		&String{Value: "a"},
+	}
+
+	for i, key := range keys {
+		hashKey := key.(Hashable).HashKey()
+		hash.Set(hashKey, HashPair{Key: key, Value: &Integer{Value: int64(i)}})
+	}
+
+	// overwriting a key keeps its original position
+	hash.Set(keys[0].(Hashable).HashKey(), HashPair{Key: keys[0], Value: &Integer{Value: 9}})
+
+	expected := "{z: 9, 1: 1, true: 2, a: 3}"
+	if hash.Inspect() != expected {
+		t.Errorf("hash.Inspect() wrong. expected=%q, got=%q", expected, hash.Inspect())
+	}
+
+	if !hash.Delete(keys[1].(Hashable).HashKey()) {
+		t.Errorf("Delete did not report the key as present")
+	}
+	if hash.Delete(keys[1].(Hashable).HashKey()) {
+		t.Errorf("Delete reported a missing key as present")
+	}
+
+	expected = "{z: 9, true: 2, a: 3}"
+	if hash.Inspect() != expected {
+		t.Errorf("hash.Inspect() wrong after delete. expected=%q, got=%q", expected, hash.Inspect())
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-3, "-3.0"},
+		{1e21, "1e+21"},
+		{0.1, "0.1"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect. expected=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package object
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//NewRuntime creates the state for a new interpreter, the module search path
+//is read from MONKEY_PATH
+func NewRuntime() *Runtime {
+	return &Runtime{
+		SearchPath: filepath.SplitList(os.Getenv("MONKEY_PATH")),
+		Rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		Globals:    make(map[string]Object),
+		Exit:       os.Exit,
+		modules:    make(map[string]*Module),
+	}
+}
+
+//Runtime is the state shared by every enviroment of one interpreter
+type Runtime struct {
+	// SearchPath lists the directories imports are looked up in after the importing file's own
+	SearchPath []string
+
+	// Rand is the interpreter's own random number generator, it is seeded
+	// from the clock unless Seed is called
+	Rand *rand.Rand
+
+	// Globals are visible from every module, like ARGS, a module's own
+	// bindings hide them
+	Globals map[string]Object
+
+	// Exit is called by the exit builtin, it is os.Exit unless the
+	// interpreter is embedded
+	Exit func(code int)
+
+	// CallDepth is the number of function calls being evaluated, the
+	// evaluator stops deep recursion with it
+	CallDepth int
+
+	modules map[string]*Module
+	loading []string
+
+	fsEnabled bool
+	fsRoots   []string
+}
+
+//Module returns the already loaded module for a resolved path
+func (r *Runtime) Module(path string) (*Module, bool) {
+	module, ok := r.modules[path]
+	return module, ok
+}
+
+//BeginLoad records that a module is being evaluated, it returns the chain of
+//imports and false when the module is already being loaded further up
+func (r *Runtime) BeginLoad(path string) ([]string, bool) {
+	for i, loading := range r.loading {
+		if loading == path {
+			cycle := append([]string{}, r.loading[i:]...)
+			return append(cycle, path), false
+		}
+	}
+	r.loading = append(r.loading, path)
+	return nil, true
+}
+
+//EndLoad finishes loading a module, caching it when it loaded successfully
+func (r *Runtime) EndLoad(path string, module *Module) {
+	r.loading = r.loading[:len(r.loading)-1]
+	if module != nil {
+		r.modules[path] = module
+	}
+}
+
+//Seed restarts the random number generator so runs can be reproduced
+func (r *Runtime) Seed(seed int64) {
+	r.Rand = rand.New(rand.NewSource(seed))
+}
+
+//ErrFSDisabled is returned by FSPath until AllowFS has been called
+var ErrFSDisabled = errors.New("file system access is disabled, run with --allow-fs")
+
+//AllowFS turns on the file system builtins, limited to paths inside the given
+//roots, with no roots the whole file system is allowed
+func (r *Runtime) AllowFS(roots ...string) error {
+	resolved := make([]string, 0, len(roots))
+	for _, root := range roots {
+		path, err := resolvePath(root)
+		if err != nil {
+			return err
+		}
+		resolved = append(resolved, path)
+	}
+	r.fsEnabled = true
+	r.fsRoots = resolved
+	return nil
+}
+
+//FSPath resolves a path a script wants to use, it fails when file access is
+//disabled or the path is outside the allowed roots
+func (r *Runtime) FSPath(path string) (string, error) {
+	if !r.fsEnabled {
+		return "", ErrFSDisabled
+	}
+
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if len(r.fsRoots) == 0 {
+		return resolved, nil
+	}
+
+	for _, root := range r.fsRoots {
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", errors.New("access denied: " + path + " is outside the allowed directories")
+}
+
+// resolvePath makes a path absolute and follows symlinks in the part of it
+// that exists, so a link can't be used to escape an allowed root
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	missing := ""
+	dir := abs
+	for {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(real, missing), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs, nil
+		}
+		missing = filepath.Join(filepath.Base(dir), missing)
+		dir = parent
+	}
+}
@@ -0,0 +1,128 @@
+package optimize
+
+import "monkey/ast"
+
+// prune removes the branches of ifs and whiles whose condition is a
+// constant. Blocks share the scope they are in, so the statements of the
+// branch that runs take the place of the if.
+func prune(program *ast.Program) bool {
+	changed := false
+	ast.Inspect(program, func(node ast.Node) bool {
+		var pruned bool
+		switch node := node.(type) {
+		case *ast.Program:
+			node.Statements, pruned = pruneStatements(node.Statements)
+		case *ast.BlockStatement:
+			node.Statements, pruned = pruneStatements(node.Statements)
+		}
+		changed = changed || pruned
+		_, quoted := ast.Quoted(node)
+		return !quoted
+	})
+
+	// an if used as a value, like let x = if (true) { 1 } else { 2 }
+	ast.Apply(program, notQuoted, func(c *ast.Cursor) bool {
+		if _, ok := c.Parent().(*ast.ExpressionStatement); ok {
+			return true
+		}
+		if value := branchValue(c.Node()); value != nil {
+			c.Replace(value)
+			changed = true
+		}
+		return true
+	})
+	return changed
+}
+
+// pruneStatements replaces each if or while statement with a constant
+// condition by the branch that runs. The last statement is the value of its
+// function, or of the program, so it is only removed when that value stays
+// the same.
+func pruneStatements(stmts []ast.Statement) ([]ast.Statement, bool) {
+	out := make([]ast.Statement, 0, len(stmts))
+	changed := false
+	for i, stmt := range stmts {
+		block, ok := branch(stmt)
+		if !ok {
+			out = append(out, stmt)
+			continue
+		}
+
+		last := i == len(stmts)-1
+		switch {
+		case block != nil && len(block.Statements) > 0:
+			spliced, _ := pruneStatements(block.Statements)
+			out = append(out, spliced...)
+		case block != nil && last:
+			// an empty block has no value, unlike the statement before it
+			out = append(out, stmt)
+			continue
+		case block == nil && last:
+			tok := stmt.(*ast.ExpressionStatement).Token
+			out = append(out, &ast.ExpressionStatement{Token: tok, Expression: null(tok)})
+		}
+		changed = true
+	}
+	return out, changed
+}
+
+// branch is the block that runs for an if or while statement with a
+// constant condition, nil when none does. ok is false for other statements.
+func branch(stmt ast.Statement) (block *ast.BlockStatement, ok bool) {
+	es, isExpression := stmt.(*ast.ExpressionStatement)
+	if !isExpression {
+		return nil, false
+	}
+
+	switch exp := es.Expression.(type) {
+	case *ast.IfExpression:
+		value, constant := truthy(exp.Condition)
+		if !constant {
+			return nil, false
+		}
+		if value {
+			return exp.Consequence, true
+		}
+		return exp.Alternative, true
+	case *ast.WhileExpression:
+		value, constant := truthy(exp.Test)
+		return nil, constant && !value
+	}
+	return nil, false
+}
+
+// branchValue is what an if or while with a constant condition evaluates
+// to, when that is a single expression
+func branchValue(node ast.Node) ast.Expression {
+	var block *ast.BlockStatement
+	switch exp := node.(type) {
+	case *ast.IfExpression:
+		value, constant := truthy(exp.Condition)
+		if !constant {
+			return nil
+		}
+		block = exp.Alternative
+		if value {
+			block = exp.Consequence
+		}
+		if block == nil {
+			return null(exp.Token)
+		}
+	case *ast.WhileExpression:
+		if value, constant := truthy(exp.Test); constant && !value {
+			return null(exp.Token)
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	if len(block.Statements) != 1 {
+		return nil
+	}
+	stmt, ok := block.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil
+	}
+	return stmt.Expression
+}
@@ -0,0 +1,175 @@
+package optimize
+
+import (
+	"math"
+	"monkey/ast"
+)
+
+// fold replaces prefix and infix operators on constants with their result
+func fold(program *ast.Program) bool {
+	changed := false
+	ast.Apply(program, notQuoted, func(c *ast.Cursor) bool {
+		var folded ast.Expression
+		switch node := c.Node().(type) {
+		case *ast.PrefixExpression:
+			folded = foldPrefix(node)
+		case *ast.InfixExpression:
+			folded = foldInfix(node)
+		}
+		if folded != nil {
+			c.Replace(folded)
+			changed = true
+		}
+		return true
+	})
+	return changed
+}
+
+// foldPrefix follows evalPrefixExpression, it is nil when the operator
+// doesn't apply to a constant or would fail
+func foldPrefix(exp *ast.PrefixExpression) ast.Expression {
+	switch exp.Operator {
+	case "!":
+		switch right := exp.Right.(type) {
+		case *ast.Boolean:
+			return boolean(exp.Token, !right.Value)
+		case *ast.Null:
+			return boolean(exp.Token, true)
+		case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral:
+			// only booleans and null are negated, anything else is false
+			return boolean(exp.Token, false)
+		}
+	case "-":
+		switch right := exp.Right.(type) {
+		case *ast.IntegerLiteral:
+			return integer(exp.Token, -right.Value)
+		case *ast.FloatLiteral:
+			return float(exp.Token, -right.Value)
+		}
+	}
+	return nil
+}
+
+// foldInfix follows evalInfIxExpression
+func foldInfix(exp *ast.InfixExpression) ast.Expression {
+	switch left := exp.Left.(type) {
+	case *ast.IntegerLiteral:
+		switch right := exp.Right.(type) {
+		case *ast.IntegerLiteral:
+			return foldIntegers(exp, left.Value, right.Value)
+		case *ast.FloatLiteral:
+			return foldFloats(exp, float64(left.Value), right.Value)
+		}
+	case *ast.FloatLiteral:
+		switch right := exp.Right.(type) {
+		case *ast.IntegerLiteral:
+			return foldFloats(exp, left.Value, float64(right.Value))
+		case *ast.FloatLiteral:
+			return foldFloats(exp, left.Value, right.Value)
+		}
+	case *ast.StringLiteral:
+		if right, ok := exp.Right.(*ast.StringLiteral); ok {
+			return foldStrings(exp, left.Value, right.Value)
+		}
+	case *ast.Boolean:
+		if right, ok := exp.Right.(*ast.Boolean); ok {
+			return foldEquality(exp, left.Value == right.Value)
+		}
+	case *ast.Null:
+		if _, ok := exp.Right.(*ast.Null); ok {
+			return foldEquality(exp, true)
+		}
+	}
+	return nil
+}
+
+func foldIntegers(exp *ast.InfixExpression, left, right int64) ast.Expression {
+	tok := literalToken(exp.Left)
+	switch exp.Operator {
+	case "+":
+		return integer(tok, left+right)
+	case "-":
+		return integer(tok, left-right)
+	case "*":
+		return integer(tok, left*right)
+	case "/":
+		if right == 0 {
+			return nil
+		}
+		return integer(tok, left/right)
+	case "%":
+		if right == 0 {
+			return nil
+		}
+		return integer(tok, left%right)
+	case "<":
+		return boolean(tok, left < right)
+	case ">":
+		return boolean(tok, left > right)
+	case "==":
+		return boolean(tok, left == right)
+	case "!=":
+		return boolean(tok, left != right)
+	}
+	return nil
+}
+
+func foldFloats(exp *ast.InfixExpression, left, right float64) ast.Expression {
+	tok := literalToken(exp.Left)
+	switch exp.Operator {
+	case "+":
+		return float(tok, left+right)
+	case "-":
+		return float(tok, left-right)
+	case "*":
+		return float(tok, left*right)
+	case "/":
+		if right == 0 {
+			return nil
+		}
+		return float(tok, left/right)
+	case "%":
+		if right == 0 {
+			return nil
+		}
+		return float(tok, math.Mod(left, right))
+	case "<":
+		return boolean(tok, left < right)
+	case ">":
+		return boolean(tok, left > right)
+	case "==":
+		return boolean(tok, left == right)
+	case "!=":
+		return boolean(tok, left != right)
+	}
+	return nil
+}
+
+func foldStrings(exp *ast.InfixExpression, left, right string) ast.Expression {
+	tok := literalToken(exp.Left)
+	switch exp.Operator {
+	case "+":
+		return str(tok, left+right)
+	case "<":
+		return boolean(tok, left < right)
+	case ">":
+		return boolean(tok, left > right)
+	case "==":
+		return boolean(tok, left == right)
+	case "!=":
+		return boolean(tok, left != right)
+	}
+	return nil
+}
+
+// foldEquality folds == and != on booleans and null, which compare identity
+func foldEquality(exp *ast.InfixExpression, equal bool) ast.Expression {
+	tok := literalToken(exp.Left)
+	switch exp.Operator {
+	case "==":
+		return boolean(tok, equal)
+	case "!=":
+		return boolean(tok, !equal)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package optimize
+
+import (
+	"monkey/ast"
+	"monkey/resolver"
+	"monkey/token"
+)
+
+// maxInlineString is the longest string copied to where it is used, longer
+// ones are left in one place
+const maxInlineString = 32
+
+// inline copies small constants bound by let to the identifiers that read
+// them. Only names bound once, by a let that runs before the rest of its
+// function, and not exported are inlined, so every read sees the constant.
+// Reads in the statements before the let can run before it binds the name
+// and are left alone, whatever their position in the source: code expanded
+// from a macro keeps the positions of the macro. Names declared again in a
+// function inside the let's are left alone too, a read there can see either
+// one when it is in a loop. A let whose reads are all inlined is removed.
+func inline(program *ast.Program) bool {
+	names := resolver.Resolve(program, func(string) bool { return true })
+	changed := false
+
+	bodies := []*[]ast.Statement{&program.Statements}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if fn, ok := node.(*ast.FunctionLiteral); ok {
+			bodies = append(bodies, &fn.Body.Statements)
+		}
+		_, quoted := ast.Quoted(node)
+		return !quoted
+	})
+
+	replacements := make(map[*ast.Identifier]ast.Expression)
+	var removed []ast.Statement
+	for _, body := range bodies {
+		var in map[*ast.Identifier]int
+		for i, stmt := range *body {
+			let, ok := stmt.(*ast.LetStatement)
+			if !ok || !inlinable(let, names) {
+				continue
+			}
+
+			if in == nil {
+				in = statementOf(*body)
+			}
+			all := true
+			for _, ref := range names.Bindings[let.Name].Declaration.Refs {
+				if j, ok := in[ref]; ok && j > i {
+					replacements[ref] = copyLiteral(let.Value, ref.Token)
+				} else {
+					all = false
+				}
+			}
+			// the last statement is the value of the function
+			if all && i != len(*body)-1 {
+				removed = append(removed, stmt)
+			}
+		}
+	}
+	if len(replacements) == 0 && len(removed) == 0 {
+		return false
+	}
+
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		switch node := c.Node().(type) {
+		case *ast.Identifier:
+			if value, ok := replacements[node]; ok {
+				c.Replace(value)
+				changed = true
+			}
+		case *ast.LetStatement:
+			for _, stmt := range removed {
+				if stmt == node {
+					c.Delete()
+					changed = true
+					return false
+				}
+			}
+		}
+		return true
+	}, nil)
+	return changed
+}
+
+func inlinable(let *ast.LetStatement, names *resolver.Result) bool {
+	if let.Name == nil || let.Token.Line == 0 {
+		return false
+	}
+	decl := names.Bindings[let.Name].Declaration
+	if decl == nil || decl.Kind != resolver.Let || len(decl.Defs) != 1 || decl.Exported || shadowed(decl, names) {
+		return false
+	}
+
+	switch value := let.Value.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean, *ast.Null:
+		return true
+	case *ast.StringLiteral:
+		return len(value.Value) <= maxInlineString
+	}
+	return false
+}
+
+// shadowed reports whether a scope inside the declaration's declares its name
+func shadowed(decl *resolver.Declaration, names *resolver.Result) bool {
+	for _, other := range names.Declarations {
+		if other == decl || other.Name != decl.Name {
+			continue
+		}
+		for s := other.Scope.Parent; s != nil; s = s.Parent {
+			if s == decl.Scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// statementOf maps the identifiers in a body to the index of the statement
+// they are in
+func statementOf(body []ast.Statement) map[*ast.Identifier]int {
+	in := make(map[*ast.Identifier]int)
+	for i, stmt := range body {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Identifier); ok {
+				in[ident] = i
+			}
+			return true
+		})
+	}
+	return in
+}
+
+// copyLiteral copies a constant to the position of tok
+func copyLiteral(exp ast.Expression, tok token.Token) ast.Expression {
+	orig := literalToken(exp)
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return &ast.IntegerLiteral{Token: at(tok, orig.Type, orig.Literal), Value: exp.Value}
+	case *ast.FloatLiteral:
+		return &ast.FloatLiteral{Token: at(tok, orig.Type, orig.Literal), Value: exp.Value}
+	case *ast.StringLiteral:
+		return str(tok, exp.Value)
+	case *ast.Boolean:
+		return boolean(tok, exp.Value)
+	}
+	return null(tok)
+}
@@ -0,0 +1,114 @@
+// Package optimize rewrites a parsed program so it does less work when it
+// runs without changing what it does. Operators on constants are computed
+// once, branches that can never run are removed and small constants bound by
+// let are copied to where they are used.
+//
+// Anything the evaluator would report as an error, like a division by zero
+// or adding a string to an integer, is left for the evaluator to report.
+//
+// Scripts and the modules they import, the standard library too, are
+// optimized before they run. The REPL's input isn't: a let removed from one
+// line could be read by the next.
+package optimize
+
+import (
+	"math"
+	"monkey/ast"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
+
+// maxPasses bounds how often the passes are repeated, each one can open up
+// work for the others
+const maxPasses = 8
+
+// Program optimizes a program in place. It should resolve without errors,
+// names are linked the way the resolver links them.
+func Program(program *ast.Program) {
+	for i := 0; i < maxPasses; i++ {
+		changed := fold(program)
+		changed = prune(program) || changed
+		changed = inline(program) || changed
+		if !changed {
+			return
+		}
+	}
+}
+
+// notQuoted skips the code given to quote, it is a value and changing it
+// would change what the program does
+func notQuoted(c *ast.Cursor) bool {
+	_, ok := ast.Quoted(c.Node())
+	return !ok
+}
+
+// truthy is whether a constant counts as true in a condition, ok is false
+// when the expression isn't a constant
+func truthy(exp ast.Expression) (value, ok bool) {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return exp.Value, true
+	case *ast.Null:
+		return false, true
+	case *ast.IntegerLiteral:
+		return exp.Value != 0, true
+	case *ast.FloatLiteral:
+		return exp.Value != 0, true
+	case *ast.StringLiteral:
+		return exp.Value != "", true
+	}
+	return false, false
+}
+
+// literalToken is the token of a constant
+func literalToken(exp ast.Expression) token.Token {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return exp.Token
+	case *ast.Null:
+		return exp.Token
+	case *ast.IntegerLiteral:
+		return exp.Token
+	case *ast.FloatLiteral:
+		return exp.Token
+	case *ast.StringLiteral:
+		return exp.Token
+	}
+	return token.Token{}
+}
+
+func at(tok token.Token, typ token.Type, literal string) token.Token {
+	return token.Token{Type: typ, Literal: literal, Line: tok.Line, Column: tok.Column}
+}
+
+func integer(tok token.Token, value int64) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{Token: at(tok, token.INT, strconv.FormatInt(value, 10)), Value: value}
+}
+
+// float is nil for values that can't be written as a literal
+func float(tok token.Token, value float64) ast.Expression {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return nil
+	}
+	literal := strconv.FormatFloat(value, 'f', -1, 64)
+	if !strings.Contains(literal, ".") {
+		literal += ".0"
+	}
+	return &ast.FloatLiteral{Token: at(tok, token.FLOAT, literal), Value: value}
+}
+
+func str(tok token.Token, value string) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: at(tok, token.STRING, value), Value: value}
+}
+
+func boolean(tok token.Token, value bool) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: at(tok, token.TRUE, "true"), Value: true}
+	}
+	return &ast.Boolean{Token: at(tok, token.FALSE, "false"), Value: false}
+}
+
+func null(tok token.Token) *ast.Null {
+	return &ast.Null{Token: at(tok, token.NULL, "null")}
+}
@@ -0,0 +1,145 @@
+package optimize_test
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/optimize"
+	"monkey/parser"
+	"testing"
+)
+
+// parse parses a program and expands its macros, like the runner does before
+// optimizing
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parser errors %v", input, p.Errors())
+	}
+	macros := object.NewModuleEnviroment(object.NewRuntime(), "")
+	evaluator.DefineMacros(program, macros)
+	if err := evaluator.ExpandMacros(program, macros); err != nil {
+		t.Fatalf("%q: %s", input, err.Inspect())
+	}
+	return program
+}
+
+func eval(program *ast.Program) string {
+	result := evaluator.Eval(program, object.NewModuleEnviroment(object.NewRuntime(), ""))
+	if result == nil {
+		return "<nil>"
+	}
+	return result.Inspect()
+}
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// folding
+		{"60 * 60 * 24", "86400"},
+		{`"a" + "b"`, "ab"},
+		{"!true", "false"},
+		{"!5; !null", "falsetrue"},
+		{"-(2 - 5) * 1.5", "4.5"},
+		{"1.5 * 2", "3.0"},
+		{"null == null; true != false", "truetrue"},
+		{`"a" < "b"; 1 == 1.0`, "truetrue"},
+		{"9223372036854775807 + 1", "-9223372036854775808"},
+		{"1 / 0", "(1 / 0)"},
+		{"10 % 0.0", "(10 % 0.0)"},
+		{`1 + "a"`, "(1 + a)"},
+		{"true + true", "(true + true)"},
+		{"-true", "(-true)"},
+
+		// dead branches
+		{"if (false) { puts(1) }; 2", "2"},
+		{"if (1 > 2) { 1 } else { 2 }", "2"},
+		{"if (0) { 1 }", "null"},
+		{`if ("s") { let a = 1; a + 1 }`, "2"},
+		{"if (true) { }", "iftrue "},
+		{"while (false) { puts(1) }", "null"},
+		{"let f = fn() { if (true) { return 1; }; 2 }; f()", "let f = fn() return 1;2;f()"},
+		{"let f = fn(x) { while (null) { x }; x }; f(1)", "let f = fn(x) x;f(1)"},
+		{"let x = if (true) { 1 } else { 2 }; x", "1"},
+		{"[if (false) { 1 }, if (true) { 2 }]", "[null, 2]"},
+
+		// inlining
+		{"let day = 60 * 60 * 24; day * 2", "172800"},
+		{"let x = 5; let f = fn() { x * 2 }; f()", "let f = fn() 10;f()"},
+		{"let f = fn() { y }; let y = 1; f()", "let f = fn() y;let y = 1;f()"},
+		{"let x = 1; let x = x + 1; x", "let x = 1;let x = (x + 1);x"},
+		{
+			"let c = len([]); if (c) { let z = 1; }; z",
+			"let c = len([]);ifc let z = 1;z",
+		},
+		{"export let k = 1; k", "export let k = 1;k"},
+		{"let f = fn(k) { let k = 2; k }; f(1)", "let f = fn(k) let k = 2;k;f(1)"},
+		{
+			`let x = 1; let f = fn() { puts(x); let x = 2; puts(x) }; f(); puts("end")`,
+			`let f = fn() puts(1)puts(2);f()puts(end)`,
+		},
+		{
+			"let x = 1; let f = fn() { let i = 0; let r = []; while (i < 2) { let r = push(r, x); let x = 2; let i = i + 1; }; r }; f()",
+			"let x = 1;let f = fn() let i = 0;let r = [];while( (i < 2) ) {\nlet r = push(r, x);let x = 2;let i = (i + 1);\n}r;f()",
+		},
+		{
+			`let s = "a string longer than thirty two bytes"; s + s`,
+			`let s = a string longer than thirty two bytes;(s + s)`,
+		},
+		{"let debug = false; if (debug) { puts(1) }; 3", "3"},
+		{"let x = 1;", "let x = 1;"},
+
+		// quoted code is a value
+		{"quote(1 + 2)", "quote((1 + 2))"},
+		{"quote(if (true) { 1 }); quote(unquote(2 * 3))", "quote(iftrue 1)quote(unquote((2 * 3)))"},
+		{"let x = 1; quote(x)", "quote(x)"},
+		{"let x = 1; quote(unquote(x) + x)", "quote((unquote(1) + x))"},
+
+		// expanded code keeps the positions of the macro
+		{
+			"let f = fn() { getx() }; let g = f; let x = 5; let getx = macro() { quote(x) }; [x, g()]",
+			"let f = fn() x;let g = f;let x = 5;[5, g()]",
+		},
+		{"getx(); let x = 5; let getx = macro() { quote(x) }; x", "xlet x = 5;5"},
+	}
+
+	for _, tt := range tests {
+		before := eval(parse(t, tt.input))
+		program := parse(t, tt.input)
+		optimize.Program(program)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: wrong program.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+		if after := eval(program); after != before {
+			t.Errorf("%q: result changed. before=%s, after=%s", tt.input, before, after)
+		}
+	}
+}
+
+func TestProgramLoop(t *testing.T) {
+	input := `
+let seconds = 60 * 60 * 24;
+let total = 0;
+let i = 0;
+while (i < 10) {
+    if (!true) { puts("never") };
+    let total = total + seconds % 7;
+    let i = i + 1;
+};
+total`
+	program := parse(t, input)
+	optimize.Program(program)
+
+	expected := "let total = 0;let i = 0;while( (i < 10) ) {\nlet total = (total + 6);let i = (i + 1);\n}total"
+	if got := program.String(); got != expected {
+		t.Errorf("wrong program.\nexpected=%q\ngot=%q", expected, got)
+	}
+	if before, after := eval(parse(t, input)), eval(program); before != after || after != "60" {
+		t.Errorf("wrong result. before=%s, after=%s", before, after)
+	}
+}
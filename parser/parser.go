@@ -6,6 +6,7 @@ import (
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -37,13 +38,15 @@ var precedences = map[token.Type]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 // Parser is the monkey language parser
 type Parser struct {
 	lexer *lexer.Lexer
 
-	errors []string
+	errors      []string
+	diagnostics []Error
 
 	curToken  token.Token
 	peekToken token.Token
@@ -65,6 +68,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.Type]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -74,6 +78,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.WHILE, p.parseWhileExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashExpression)
@@ -90,23 +95,45 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	return p
 }
 
+// Error is a parse error at the position of the token it was found at
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
 // Errors is the list of errors while parsing the program
 func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Diagnostics is the list of errors with their positions, in the same order as Errors
+func (p *Parser) Diagnostics() []Error {
+	return p.diagnostics
+}
+
+func (p *Parser) addError(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.diagnostics = append(p.diagnostics, Error{Message: msg, Line: tok.Line, Column: tok.Column})
+}
+
 func (p *Parser) noPrefixParseFnError(t token.Type) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
 
 func (p *Parser) peekError(t token.Type) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 func (p *Parser) nextToken() {
@@ -179,6 +206,10 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -192,6 +223,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.checkBindable(stmt.Name)
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if stmt.Type = p.parseTypeAnnotation(); stmt.Type == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -222,6 +261,90 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// parseImportStatement parses import "<path>" as <name>, without the alias
+// the module is bound to the last element of its path. as is only special
+// here, elsewhere it is an ordinary name.
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "as" {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.checkBindable(stmt.Alias)
+	} else {
+		name := moduleName(stmt.Path.Value)
+		if !isIdentifier(name) {
+			msg := fmt.Sprintf("cannot name module %q, add an alias with `as`", stmt.Path.Value)
+			p.addError(p.curToken, msg)
+			return nil
+		}
+		tok := token.Token{Type: token.IDENT, Literal: name, Line: p.curToken.Line, Column: p.curToken.Column}
+		stmt.Alias = &ast.Identifier{Token: tok, Value: name}
+		p.checkBindable(stmt.Alias)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	let := p.parseLetStatement()
+	if let == nil {
+		return nil
+	}
+	stmt.Statement = let
+	if lit, ok := let.Value.(*ast.MacroLiteral); ok {
+		// macros are expanded before a module runs, an importer never sees them
+		p.addError(lit.Token, "macros cannot be exported")
+	}
+
+	return stmt
+}
+
+// checkBindable reports binding quote or unquote, a call to them always
+// means quoted code so a binding could never be called
+func (p *Parser) checkBindable(ident *ast.Identifier) {
+	if ident.Value == "quote" || ident.Value == "unquote" {
+		p.addError(ident.Token, fmt.Sprintf("cannot bind %s, it is reserved for macros", ident.Value))
+	}
+}
+
+// moduleName is the base name of an import path without its extension
+func moduleName(path string) string {
+	name := path[strings.LastIndex(path, "/")+1:]
+	return strings.TrimSuffix(name, ".mky")
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, ch := range name {
+		isDigit := '0' <= ch && ch <= '9'
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || isDigit && i > 0) {
+			return false
+		}
+	}
+	return token.LookupIdent(name) == token.IDENT
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -339,7 +462,20 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 	lit.Value = value
@@ -357,7 +493,40 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.ParameterTypes = p.parseFunctionParameters()
+
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		if lit.ReturnType = p.parseTypeAnnotation(); lit.ReturnType == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+// parseMacroLiteral parses macro(a, b) { ... }, its parameters can't have
+// types since they are always code
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	var types []*ast.TypeAnnotation
+	lit.Parameters, types = p.parseFunctionParameters()
+	for _, typ := range types {
+		if typ != nil {
+			p.addError(typ.Token, "macro parameters can't have types")
+		}
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -368,30 +537,55 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses the parameters and their optional type
+// annotations, types has an entry for each parameter
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []*ast.TypeAnnotation) {
 	identifiers := []*ast.Identifier{}
+	types := []*ast.TypeAnnotation{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, types
 	}
 
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
-
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+	for {
 		p.nextToken()
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.checkBindable(ident)
 		identifiers = append(identifiers, ident)
+
+		var typ *ast.TypeAnnotation
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			if typ = p.parseTypeAnnotation(); typ == nil {
+				return nil, nil
+			}
+		}
+		types = append(types, typ)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
 	}
 
-	return identifiers
+	return identifiers, types
+}
+
+// parseTypeAnnotation parses the type name after a : or ->, null and fn are
+// keywords but also name types
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	switch p.peekToken.Type {
+	case token.IDENT, token.NULL, token.FUNCTION:
+		p.nextToken()
+		return &ast.TypeAnnotation{Token: p.curToken, Name: p.curToken.Literal}
+	}
+	p.addError(p.peekToken, fmt.Sprintf("expected next token to be a type name, got %s", p.peekToken.Type))
+	return nil
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -481,10 +675,46 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	exp := &ast.IndexExpression{Token: tok, Left: left, Index: index}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseSliceExpression parses the rest of <left>[<start>:<end>:<step>] once
+// the start, which may be missing, has been read
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
 
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			exp.Step = p.parseExpression(LOWEST)
+		}
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -493,6 +723,17 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) parseHashExpression() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -511,6 +752,7 @@ func (p *Parser) parseHashExpression() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -742,6 +742,114 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let f: fn = fn(a: int, b) -> bool { true };", "let f: fn = fn(a: int, b) -> bool true;"},
+		{"fn(a: null, b: string) { a };", "fn(a: null, b: string) a"},
+		{"fn() -> fn { fn() -> int { 1 } };", "fn() -> fn fn() -> int 1"},
+		{"let x = a - b;", "let x = (a - b);"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New("fn(a: int, b) {}")).ParseProgram()
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if function.ParameterType(0).Name != "int" || function.ParameterType(1) != nil || function.ParameterType(2) != nil {
+		t.Errorf("wrong parameter types %v", function.ParameterTypes)
+	}
+
+	p := New(lexer.New("let x: 5 = 5;"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "expected next token to be a type name, got INT" {
+		t.Errorf("wrong errors for a bad annotation: %v", p.Errors())
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+
+	p = New(lexer.New("macro(x: int) { x }"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "macro parameters can't have types" {
+		t.Errorf("wrong errors for a typed parameter: %v", p.Errors())
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let quote = fn(s) { "> " + s }; puts(quote("hi"))`, "cannot bind quote, it is reserved for macros"},
+		{`fn(unquote) { 1 }`, "cannot bind unquote, it is reserved for macros"},
+		{`import "lib/quote"`, "cannot bind quote, it is reserved for macros"},
+		{`export let m = macro(x) { x };`, "macros cannot be exported"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) != 1 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %s. expected=%q, got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -865,6 +973,79 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"myArray[1:2]", "(myArray[1:2])"},
+		{"myArray[:2]", "(myArray[:2])"},
+		{"myArray[1:]", "(myArray[1:])"},
+		{"myArray[:]", "(myArray[:])"},
+		{"myArray[::2]", "(myArray[::2])"},
+		{"myArray[1:-1:2]", "(myArray[1:(-1):2])"},
+		{"myArray[a + 1:b * 2]", "(myArray[(a + 1):(b * 2)])"},
+		{"myArray[1:2][0]", "((myArray[1:2])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestImportExportStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/math.mky" as m;`, `import "lib/math.mky" as m;`},
+		{`import "lib/strings"`, `import "lib/strings" as strings;`},
+		{`export let x = 5;`, `export let x = 5;`},
+		{`m.add(1, 2)`, `m.add(1, 2)`},
+		{`a.b.c[0]`, `(a.b.c[0])`},
+		{`let as = 1;`, `let as = 1;`},
+		{`import "lib/as" as as;`, `import "lib/as" as as;`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []string{
+		`import "my-lib"`,
+		`import lib`,
+		`export 5`,
+		`a.5`,
+	}
+
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -901,6 +1082,28 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	}
 }
 
+func TestParsingHashLiteralKeepsSourceOrder(t *testing.T) {
+	input := `{"z": 1, "a": 2, "m": 3, "b": 4}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := `{z:1, a:2, m:3, b:4}`
+	for i := 0; i < 10; i++ {
+		if hash.String() != expected {
+			t.Fatalf("hash.String() wrong. expected=%q, got=%q", expected, hash.String())
+		}
+	}
+}
+
 func TestParsingEmptyHashLiteral(t *testing.T) {
 	input := "{}"
 
@@ -966,3 +1169,31 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestErrorPositions(t *testing.T) {
+	input := "let x = 1;\nlet = 2;\nputs(x +);"
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	expected := []string{
+		"2:5: expected next token to be IDENT, got =",
+		"2:5: no prefix parse function for = found",
+		"3:9: no prefix parse function for ) found",
+		"3:10: expected next token to be ), got ;",
+	}
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != len(expected) || len(p.Errors()) != len(expected) {
+		t.Fatalf("wrong number of errors. got=%d diagnostics and %d errors, want=%d",
+			len(diagnostics), len(p.Errors()), len(expected))
+	}
+	for i, want := range expected {
+		if diagnostics[i].Error() != want {
+			t.Errorf("wrong error. expected=%q, got=%q", want, diagnostics[i].Error())
+		}
+		if diagnostics[i].Message != p.Errors()[i] {
+			t.Errorf("diagnostic %q doesn't match error %q", diagnostics[i].Message, p.Errors()[i])
+		}
+	}
+}
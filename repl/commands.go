@@ -0,0 +1,261 @@
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// command is a repl meta-command, typed as :name followed by its argument
+type command struct {
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+var commands map[string]command
+
+// commands is filled in init as :help lists the commands
+func init() {
+	commands = map[string]command{
+		"help":   {":help", "list the commands", helpCommand},
+		"env":    {":env", "list the bindings in the session", envCommand},
+		"load":   {":load <file>", "run a file in the session, keeping its bindings", loadCommand},
+		"reset":  {":reset", "forget every binding", resetCommand},
+		"type":   {":type <expr>", "evaluate an expression and show the type of its value", typeCommand},
+		"ast":    {":ast <expr>", "show the syntax tree of the input", astCommand},
+		"tokens": {":tokens <expr>", "show the tokens of the input", tokensCommand},
+		"time":   {":time <expr>", "evaluate the input and show how long it took", timeCommand},
+	}
+}
+
+// metaCommand splits input like `:type 1 + 2` into the command name and its
+// argument
+func metaCommand(input string) (string, string, bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, ":") {
+		return "", "", false
+	}
+
+	name := input[1:]
+	arg := ""
+	if i := strings.IndexAny(name, " \t\n"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i:])
+	}
+	return name, arg, true
+}
+
+// needsMore is incomplete for input and the argument of meta-commands
+func needsMore(input string) bool {
+	if _, arg, ok := metaCommand(input); ok {
+		return incomplete(arg)
+	}
+	return incomplete(input)
+}
+
+func (s *session) run(name, arg string) {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, try :help\n", name)
+		return
+	}
+	cmd.run(s, arg)
+}
+
+// requireArg prints the usage of a command that needs an argument
+func (s *session) requireArg(name, arg string) bool {
+	if arg == "" {
+		fmt.Fprintf(s.out, "usage: %s\n", commands[name].usage)
+		return false
+	}
+	return true
+}
+
+func helpCommand(s *session, arg string) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(s.out, "  %-16s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintf(s.out, "  %-16s %s\n", "exit", "leave the repl")
+}
+
+func envCommand(s *session, arg string) {
+	for _, name := range s.env.Names() {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, summary(value))
+	}
+}
+
+// summary shortens a value to its first line and at most 60 characters
+func summary(obj object.Object) string {
+	text := obj.Inspect()
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i] + " ..."
+	}
+	if runes := []rune(text); len(runes) > 60 {
+		text = string(runes[:57]) + "..."
+	}
+	return text
+}
+
+func loadCommand(s *session, arg string) {
+	if !s.requireArg("load", arg) {
+		return
+	}
+
+	source, err := ioutil.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	program := s.parse(string(source))
+	if program == nil {
+		return
+	}
+
+	// the file runs as a module of its own so its imports are found from
+	// its directory, then its bindings and macros join the session
+	path, err := filepath.Abs(arg)
+	if err != nil {
+		path = arg
+	}
+	env := object.NewModuleEnviroment(s.runtime, path)
+	macros := object.NewModuleEnviroment(s.runtime, path)
+	evaluator.DefineMacros(program, macros)
+	result := evaluator.ExpandMacros(program, macros)
+	if result == nil {
+		result = evaluator.Eval(program, env)
+	}
+	for _, name := range macros.Names() {
+		value, _ := macros.Get(name)
+		s.macros.Set(name, value)
+	}
+	for _, name := range env.Names() {
+		value, _ := env.Get(name)
+		s.env.Set(name, value)
+	}
+	if result, ok := result.(*object.Error); ok {
+		s.print(result)
+	}
+}
+
+func resetCommand(s *session, arg string) {
+	s.env = object.NewModuleEnviroment(s.runtime, "")
+	s.macros = object.NewModuleEnviroment(s.runtime, "")
+}
+
+func typeCommand(s *session, arg string) {
+	if !s.requireArg("type", arg) {
+		return
+	}
+
+	evaluated := s.eval(arg)
+	if evaluated == nil {
+		return
+	}
+	if evaluated.Type() == object.ErrorObj {
+		s.print(evaluated)
+		return
+	}
+	fmt.Fprintln(s.out, evaluated.Type())
+}
+
+func timeCommand(s *session, arg string) {
+	if !s.requireArg("time", arg) {
+		return
+	}
+
+	start := time.Now()
+	evaluated := s.eval(arg)
+	elapsed := time.Since(start)
+
+	s.print(evaluated)
+	fmt.Fprintf(s.out, "time: %s\n", elapsed)
+}
+
+func tokensCommand(s *session, arg string) {
+	l := lexer.New(arg)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%-10s %q\n", tok.Type, tok.Literal)
+	}
+}
+
+func astCommand(s *session, arg string) {
+	program := s.parse(arg)
+	if program == nil {
+		return
+	}
+	dumpNode(s, "", program, 0)
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// dumpNode prints a node and its children, one per line, indented by depth
+func dumpNode(s *session, label string, node ast.Node, depth int) {
+	v := reflect.ValueOf(node)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	line := strings.Repeat("  ", depth) + label + v.Type().Name()
+	if hash, ok := node.(*ast.HashLiteral); ok {
+		// the values are kept in a map, show them next to their keys
+		fmt.Fprintln(s.out, line)
+		for i, key := range hash.Keys {
+			dumpNode(s, fmt.Sprintf("Keys[%d]: ", i), key, depth+1)
+			dumpNode(s, fmt.Sprintf("Values[%d]: ", i), hash.Pairs[key], depth+1)
+		}
+		return
+	}
+
+	var children []func()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+		if field.PkgPath != "" || field.Name == "Token" {
+			continue
+		}
+
+		switch {
+		case value.Type().Implements(nodeType):
+			if value.IsNil() {
+				continue
+			}
+			child := value.Interface().(ast.Node)
+			name := field.Name
+			children = append(children, func() { dumpNode(s, name+": ", child, depth+1) })
+		case value.Kind() == reflect.Slice && value.Type().Elem().Implements(nodeType):
+			for j := 0; j < value.Len(); j++ {
+				child := value.Index(j).Interface().(ast.Node)
+				name := fmt.Sprintf("%s[%d]: ", field.Name, j)
+				children = append(children, func() { dumpNode(s, name, child, depth+1) })
+			}
+		case value.Kind() == reflect.String:
+			line += fmt.Sprintf(" %s=%q", field.Name, value.Interface())
+		case value.Kind() != reflect.Slice && value.Kind() != reflect.Map:
+			line += fmt.Sprintf(" %s=%v", field.Name, value.Interface())
+		}
+	}
+
+	fmt.Fprintln(s.out, line)
+	for _, child := range children {
+		child()
+	}
+}
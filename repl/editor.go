@@ -0,0 +1,288 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// errInterrupted is returned by ReadLine when ctrl-c is pressed
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads one line of input after showing a prompt
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// completer returns the offset in line where the word being completed
+// starts and the texts that could replace it
+type completer func(line string) (int, []string)
+
+// newLineReader edits lines in the terminal when in is one, otherwise lines
+// are read as they come
+func newLineReader(in io.Reader, out io.Writer, complete completer) lineReader {
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		return &editor{
+			in:       bufio.NewReader(f),
+			out:      out,
+			history:  loadHistory(historyPath()),
+			complete: complete,
+			raw:      func() (func(), error) { return makeRaw(f.Fd()) },
+		}
+	}
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (s *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(s.out, prompt)
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return s.scanner.Text(), nil
+}
+
+// editor reads lines from a terminal in raw mode, supporting the arrow keys,
+// the usual emacs style control keys and history
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete completer
+
+	// raw puts the terminal in raw mode, returning a function restoring it
+	raw func() (func(), error)
+}
+
+// editLine is the state of the line being edited
+type editLine struct {
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+func (e *editor) ReadLine(prompt string) (string, error) {
+	restore, err := e.raw()
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	line := &editLine{prompt: prompt}
+	// recalled is the history entry shown, len(entries) is the new line
+	recalled := len(e.history.entries)
+	current := ""
+	e.render(line)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			io.WriteString(e.out, "\r\n")
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			io.WriteString(e.out, "\r\n")
+			text := string(line.buf)
+			e.history.Add(text)
+			return text, nil
+		case 3: // ctrl-c
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // ctrl-d
+			if len(line.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			line.delete()
+		case 127, 8: // backspace
+			if line.pos > 0 {
+				line.pos--
+				line.delete()
+			}
+		case 1: // ctrl-a
+			line.pos = 0
+		case 5: // ctrl-e
+			line.pos = len(line.buf)
+		case 2: // ctrl-b
+			line.left()
+		case 6: // ctrl-f
+			line.right()
+		case 11: // ctrl-k
+			line.buf = line.buf[:line.pos]
+		case 21: // ctrl-u
+			line.buf = line.buf[line.pos:]
+			line.pos = 0
+		case '\t':
+			e.completeWord(line)
+		case 16: // ctrl-p
+			recalled, current = e.recall(line, recalled, recalled-1, current)
+		case 14: // ctrl-n
+			recalled, current = e.recall(line, recalled, recalled+1, current)
+		case 27:
+			switch e.escape() {
+			case 'A':
+				recalled, current = e.recall(line, recalled, recalled-1, current)
+			case 'B':
+				recalled, current = e.recall(line, recalled, recalled+1, current)
+			case 'C':
+				line.right()
+			case 'D':
+				line.left()
+			case 'H':
+				line.pos = 0
+			case 'F':
+				line.pos = len(line.buf)
+			case '~':
+				line.delete()
+			}
+		default:
+			if r >= ' ' {
+				line.insert(r)
+			}
+		}
+
+		e.render(line)
+	}
+}
+
+// escape reads the rest of an escape sequence and returns the key it stands
+// for: the arrow letters, H and F for home and end and ~ for delete. A
+// terminal sends a whole sequence at once, so an escape with nothing after
+// it yet is the escape key on its own and the next key is left unread.
+func (e *editor) escape() rune {
+	if e.in.Buffered() == 0 {
+		return 0
+	}
+	if next, err := e.in.Peek(1); err != nil || (next[0] != '[' && next[0] != 'O') {
+		return 0
+	}
+	e.in.ReadByte()
+
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0
+	}
+	if r < '0' || r > '9' {
+		return r
+	}
+
+	// sequences like 3~ for delete and 1~ or 7~ for home
+	number := r
+	for r >= '0' && r <= '9' {
+		if r, _, err = e.in.ReadRune(); err != nil {
+			return 0
+		}
+	}
+	if r != '~' {
+		return 0
+	}
+	switch number {
+	case '1', '7':
+		return 'H'
+	case '4', '8':
+		return 'F'
+	case '3':
+		return '~'
+	}
+	return 0
+}
+
+// recall replaces the line with another history entry, the line being typed
+// is kept in current so moving back down restores it
+func (e *editor) recall(line *editLine, from, to int, current string) (int, string) {
+	if to < 0 || to > len(e.history.entries) {
+		return from, current
+	}
+	if from == len(e.history.entries) {
+		current = string(line.buf)
+	}
+
+	text := current
+	if to < len(e.history.entries) {
+		text = e.history.entries[to]
+	}
+	line.buf = []rune(text)
+	line.pos = len(line.buf)
+	return to, current
+}
+
+// completeWord extends the word before the cursor as far as all the
+// candidates agree, when that adds nothing the candidates are listed
+func (e *editor) completeWord(line *editLine) {
+	if e.complete == nil {
+		return
+	}
+
+	before := string(line.buf[:line.pos])
+	start, candidates := e.complete(before)
+	if len(candidates) == 0 {
+		return
+	}
+
+	word := before[start:]
+	common := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	for !utf8.ValidString(common) {
+		common = common[:len(common)-1]
+	}
+
+	if len(common) > len(word) && strings.HasPrefix(common, word) {
+		for _, r := range common[len(word):] {
+			line.insert(r)
+		}
+		return
+	}
+	if len(candidates) > 1 {
+		io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+}
+
+func (e *editor) render(line *editLine) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", line.prompt, string(line.buf))
+	if back := len(line.buf) - line.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (l *editLine) insert(r rune) {
+	l.buf = append(l.buf, 0)
+	copy(l.buf[l.pos+1:], l.buf[l.pos:])
+	l.buf[l.pos] = r
+	l.pos++
+}
+
+// delete removes the character under the cursor
+func (l *editLine) delete() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+func (l *editLine) left() {
+	if l.pos > 0 {
+		l.pos--
+	}
+}
+
+func (l *editLine) right() {
+	if l.pos < len(l.buf) {
+		l.pos++
+	}
+}
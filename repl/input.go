@@ -0,0 +1,65 @@
+package repl
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+)
+
+// continues holds the tokens that can't end an input, the expression goes on
+// on the next line
+var continues = map[token.Type]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.LT:       true,
+	token.GT:       true,
+	token.MODULO:   true,
+	token.EQ:       true,
+	token.NOTEQ:    true,
+	token.COMMA:    true,
+	token.COLON:    true,
+	token.DOT:      true,
+}
+
+// incomplete reports whether the input needs more lines before it can be
+// parsed: a bracket or string is still open, or it ends with an operator
+func incomplete(input string) bool {
+	if unclosedString(input) {
+		return true
+	}
+
+	depth := 0
+	last := token.Token{Type: token.EOF}
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+
+	return depth > 0 || continues[last.Type]
+}
+
+func unclosedString(input string) bool {
+	inString := false
+	for i := 0; i < len(input); i++ {
+		switch {
+		case inString && input[i] == '\\':
+			i++
+		case input[i] == '"':
+			inString = !inString
+		case !inString && input[i] == '/' && i+1 < len(input) && input[i+1] == '/':
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		}
+	}
+	return inString
+}
@@ -1,49 +1,119 @@
 package repl
 
 import (
-	"bufio"
-	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/completion"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 )
 
 const prompt = ">> "
 
-// Start initiates a repl
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnviroment()
+// continuePrompt is shown while the input so far is incomplete
+const continuePrompt = ".. "
+
+// Start initiates a repl, evaluating in a new enviroment of the given runtime
+func Start(in io.Reader, out io.Writer, runtime *object.Runtime) {
+	s := &session{
+		out:     out,
+		runtime: runtime,
+		env:     object.NewModuleEnviroment(runtime, ""),
+		macros:  object.NewModuleEnviroment(runtime, ""),
+	}
+	reader := newLineReader(in, out, s.complete)
 
 	for {
-		fmt.Fprintf(out, prompt)
-		scanned := scanner.Scan()
-		if !scanned {
+		input, err := readInput(reader)
+		if err == errInterrupted {
+			continue
+		}
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
-		if line == "exit" {
+		if strings.TrimSpace(input) == "exit" {
 			break
 		}
-		l := lexer.New(line)
-		p := parser.New(l)
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if name, arg, ok := metaCommand(input); ok {
+			s.run(name, arg)
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		s.print(s.eval(input))
+	}
+}
+
+// session is the state of one repl
+type session struct {
+	out     io.Writer
+	runtime *object.Runtime
+	env     *object.Enviroment
+	// macros holds the macros defined so far
+	macros *object.Enviroment
+}
+
+// parse parses the input, printing any errors and returning nil
+func (s *session) parse(input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil
+	}
+	return program
+}
+
+// eval evaluates the input in the session's enviroment, it returns nil when
+// the input doesn't parse
+func (s *session) eval(input string) object.Object {
+	program := s.parse(input)
+	if program == nil {
+		return nil
+	}
+	evaluator.DefineMacros(program, s.macros)
+	if err := evaluator.ExpandMacros(program, s.macros); err != nil {
+		return err
+	}
+	return evaluator.Eval(program, s.env)
+}
+
+// complete offers the names and hash keys that can finish the line
+func (s *session) complete(line string) (int, []string) {
+	result := completion.Complete(line, s.env)
+	texts := make([]string, len(result.Candidates))
+	for i, candidate := range result.Candidates {
+		texts[i] = candidate.Text
+	}
+	return result.Start, texts
+}
+
+func (s *session) print(evaluated object.Object) {
+	if evaluated != nil && evaluated.Type() != object.NullObj {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// readInput reads lines until they make up a complete input
+func readInput(reader lineReader) (string, error) {
+	input, err := reader.ReadLine(prompt)
+	if err != nil {
+		return "", err
+	}
 
-		if evaluated != nil && evaluated.Type() != object.NullObj {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+	for needsMore(input) {
+		line, err := reader.ReadLine(continuePrompt)
+		if err != nil {
+			return "", err
 		}
+		input += "\n" + line
 	}
+	return input, nil
 }
 
 const monkeyFace = `            __,__
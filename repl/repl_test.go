@@ -0,0 +1,260 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"monkey/object"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`let a = 1;`, false},
+		{`let f = fn(x) {`, true},
+		{"let f = fn(x) {\n x\n};", false},
+		{`[1, 2,`, true},
+		{`{"a": 1`, true},
+		{`puts(1`, true},
+		{`let a = 1 +`, true},
+		{`let a =`, true},
+		{`a.`, true},
+		{`"unclosed`, true},
+		{`"a \" b"`, false},
+		{`"a \\"`, false},
+		{`"{"`, false},
+		{`1 // {`, false},
+		{`1 // "`, false},
+		{`}`, false},
+		{``, false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("incomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiLine(t *testing.T) {
+	input := "let f = fn(x) {\n  x * 2\n};\nf(\n4)\n[1,\n2]\nexit\nputs(1)\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, object.NewRuntime())
+
+	expected := ">> .. .. >> .. 8\n>> .. [1, 2]\n>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func testEditor(input string, entries ...string) *editor {
+	return &editor{
+		in:      bufio.NewReader(strings.NewReader(input)),
+		out:     ioutil.Discard,
+		history: &history{entries: entries},
+		raw:     func() (func(), error) { return func() {}, nil },
+	}
+}
+
+func TestEditor(t *testing.T) {
+	tests := []struct {
+		input    string
+		history  []string
+		expected string
+	}{
+		{"abc\r", nil, "abc"},
+		{"ab\x1b[D\x1b[Dc\r", nil, "cab"},
+		{"ab\x1b[Dc\x1b[C!\r", nil, "acb!"},
+		{"abc\x7f\x7fd\r", nil, "ad"},
+		{"abc\x01x\x05y\r", nil, "xabcy"},
+		{"abc\x1b[H\x1b[3~\r", nil, "bc"},
+		{"abc\x02\x02\x0b\r", nil, "a"},
+		{"abc\x02\x15\r", nil, "c"},
+		{"\x1b[A\r", []string{"one", "two"}, "two"},
+		{"\x1b[A\x1b[A\x1b[A\r", []string{"one", "two"}, "one"},
+		{"new\x1b[A\x1b[B\r", []string{"one"}, "new"},
+		{"\x1b[Ax\r", []string{"one"}, "onex"},
+		{"héllo\x1b[D\x1b[D\x1b[D\x7f\r", nil, "hllo"},
+	}
+
+	for _, tt := range tests {
+		e := testEditor(tt.input, tt.history...)
+		line, err := e.ReadLine(prompt)
+		if err != nil {
+			t.Fatalf("ReadLine(%q) failed: %s", tt.input, err)
+		}
+		if line != tt.expected {
+			t.Errorf("ReadLine(%q) wrong. expected=%q, got=%q", tt.input, tt.expected, line)
+		}
+	}
+}
+
+// chunkReader returns one chunk per Read, like keys arriving from a terminal
+type chunkReader []string
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(*c) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, (*c)[0])
+	*c = (*c)[1:]
+	return n, nil
+}
+
+func TestEditorEscapeKey(t *testing.T) {
+	if line, _ := testEditor("\x1bx\r").ReadLine(prompt); line != "x" {
+		t.Errorf("the key after escape should not be swallowed, got=%q", line)
+	}
+
+	e := testEditor("")
+	e.in = bufio.NewReader(&chunkReader{"ab\x1b", "c\x1b[D", "\x1b", "\x1b[D!\r"})
+	if line, err := e.ReadLine(prompt); err != nil || line != "a!bc" {
+		t.Errorf("a lone escape should be ignored, got=%q, %v", line, err)
+	}
+}
+
+func TestEditorControlKeys(t *testing.T) {
+	if _, err := testEditor("ab\x03").ReadLine(prompt); err != errInterrupted {
+		t.Errorf("ctrl-c should interrupt, got=%v", err)
+	}
+	if _, err := testEditor("\x04").ReadLine(prompt); err != io.EOF {
+		t.Errorf("ctrl-d on an empty line should end input, got=%v", err)
+	}
+	if line, _ := testEditor("ab\x01\x04\r").ReadLine(prompt); line != "b" {
+		t.Errorf("ctrl-d should delete under the cursor, got=%q", line)
+	}
+
+	e := testEditor("one\rone\r  \rtwo\r")
+	for i := 0; i < 4; i++ {
+		e.ReadLine(prompt)
+	}
+	if strings.Join(e.history.entries, ",") != "one,two" {
+		t.Errorf("wrong history. got=%q", e.history.entries)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h := loadHistory(path)
+	h.Add("let a = 1;")
+	h.Add("a")
+
+	loaded := loadHistory(path)
+	if strings.Join(loaded.entries, ",") != "let a = 1;,a" {
+		t.Fatalf("wrong history loaded. got=%q", loaded.entries)
+	}
+
+	for i := 0; i < maxHistory+10; i++ {
+		loaded.Add(strconv.Itoa(i))
+	}
+	trimmed := loadHistory(path)
+	if len(trimmed.entries) != maxHistory || trimmed.entries[0] != "10" {
+		t.Errorf("history not trimmed. got %d entries starting at %q",
+			len(trimmed.entries), trimmed.entries[0])
+	}
+}
+
+func TestMetaCommands(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.mky")
+	if err := ioutil.WriteFile(lib, []byte("let add = fn(a, b) { a + b };"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a file that imports another from its own directory
+	util := filepath.Join(dir, "util.mky")
+	if err := ioutil.WriteFile(util, []byte("export let add = fn(a, b) { a + b };"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.mky")
+	if err := ioutil.WriteFile(main, []byte(`import "util.mky" as util; let sum = util.add;`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":env", ""},
+		{"let b = \"x\"; let a = [1, 2];\n:env", "a = [1, 2]\nb = x\n"},
+		{"let f = fn(x) {\nx\n};\n:env", "f = fn(x) { ...\n"},
+		{":load " + lib + "\nadd(1, 2)", "3\n"},
+		{":load " + main + "\nsum(1, 2)", "3\n"},
+		{":load " + filepath.Join(dir, "missing.mky"), "open " + filepath.Join(dir, "missing.mky") + ": no such file or directory\n"},
+		{"let a = 1;\n:reset\n:env\na", "Error: identifier not found: a\n"},
+		{":type 1.5", "FLOAT\n"},
+		{":type [1,\n2]", "ARRAY\n"},
+		{":type nope", "Error: identifier not found: nope\n"},
+		{":type", "usage: :type <expr>\n"},
+		{":tokens a[1]", "IDENT      \"a\"\n[          \"[\"\nINT        \"1\"\n]          \"]\"\n"},
+		{":ast -a", "Program\n  Statements[0]: ExpressionStatement\n    Expression: PrefixExpression Operator=\"-\"\n      Right: Identifier Value=\"a\"\n"},
+		{":ast {1: 2}", "Program\n  Statements[0]: ExpressionStatement\n    Expression: HashLiteral\n      Keys[0]: IntegerLiteral Value=1\n      Values[0]: IntegerLiteral Value=2\n"},
+		{":nope", "unknown command :nope, try :help\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input+"\n"), &out, object.NewRuntime())
+
+		got := strings.ReplaceAll(out.String(), continuePrompt, "")
+		got = strings.ReplaceAll(got, prompt, "")
+		if got != tt.expected {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	var out bytes.Buffer
+	Start(strings.NewReader(":time 1 + 1\n"), &out, object.NewRuntime())
+	if !strings.HasPrefix(out.String(), ">> 2\ntime: ") {
+		t.Errorf("wrong :time output. got=%q", out.String())
+	}
+}
+
+func TestEditorCompletion(t *testing.T) {
+	complete := func(line string) (int, []string) {
+		start := strings.LastIndex(line, " ") + 1
+		var found []string
+		for _, word := range []string{"counter", "count_all", "héllo", "hélas"} {
+			if strings.HasPrefix(word, line[start:]) {
+				found = append(found, word)
+			}
+		}
+		return start, found
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x + cou\t\r", "x + count"},
+		{"x + count_\t\r", "x + count_all"},
+		{"h\t\r", "hél"},
+		{"zz\t\r", "zz"},
+		{"cou)\x1b[D\t\r", "count)"},
+	}
+
+	for _, tt := range tests {
+		e := testEditor(tt.input)
+		e.complete = complete
+		line, _ := e.ReadLine(prompt)
+		if line != tt.expected {
+			t.Errorf("ReadLine(%q) wrong. expected=%q, got=%q", tt.input, tt.expected, line)
+		}
+	}
+
+	var out bytes.Buffer
+	e := testEditor("count\t\r")
+	e.out = &out
+	e.complete = complete
+	e.ReadLine(prompt)
+	if !strings.Contains(out.String(), "\r\ncounter  count_all\r\n") {
+		t.Errorf("candidates not listed. got=%q", out.String())
+	}
+}
@@ -0,0 +1,66 @@
+//go:build windows
+// +build windows
+
+package repl
+
+import "syscall"
+
+// console modes from the Windows API
+const (
+	enableProcessedInput            = 0x0001
+	enableLineInput                 = 0x0002
+	enableEchoInput                 = 0x0004
+	enableVirtualTerminalInput      = 0x0200
+	enableProcessedOutput           = 0x0001
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+func isTerminal(fd uintptr) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(fd), &mode) == nil
+}
+
+// makeRaw switches the console to raw mode so keys arrive one at a time
+// without being echoed. Keys like the arrows arrive as the same escape
+// sequences a unix terminal sends, and the output understands them too. The
+// returned function restores the old modes.
+func makeRaw(fd uintptr) (func(), error) {
+	in := syscall.Handle(fd)
+	var oldIn uint32
+	if err := syscall.GetConsoleMode(in, &oldIn); err != nil {
+		return nil, err
+	}
+	raw := oldIn&^(enableEchoInput|enableLineInput|enableProcessedInput) | enableVirtualTerminalInput
+	if err := consoleMode(in, raw); err != nil {
+		return nil, err
+	}
+
+	out, err := syscall.GetStdHandle(syscall.STD_OUTPUT_HANDLE)
+	if err != nil {
+		consoleMode(in, oldIn)
+		return nil, err
+	}
+	var oldOut uint32
+	if err := syscall.GetConsoleMode(out, &oldOut); err != nil {
+		consoleMode(in, oldIn)
+		return nil, err
+	}
+	if err := consoleMode(out, oldOut|enableProcessedOutput|enableVirtualTerminalProcessing); err != nil {
+		consoleMode(in, oldIn)
+		return nil, err
+	}
+
+	return func() {
+		consoleMode(in, oldIn)
+		consoleMode(out, oldOut)
+	}, nil
+}
+
+func consoleMode(handle syscall.Handle, mode uint32) error {
+	if ok, _, err := setConsoleMode.Call(uintptr(handle), uintptr(mode)); ok == 0 {
+		return err
+	}
+	return nil
+}
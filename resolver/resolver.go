@@ -0,0 +1,345 @@
+// Package resolver links the identifiers of a parsed program to the names
+// they refer to before the program runs, so undefined names are found even in
+// code that is rarely reached.
+//
+// Scopes follow the evaluator: only functions create one, blocks share the
+// scope they are in and a let of a name already bound in the scope binds it
+// again rather than declaring a new name. Names are looked up when a
+// function is called rather than when it is defined, so a name can be used
+// anywhere in the scopes inside the one it is declared in. In its own scope a
+// read before the first let of the name sees the name from outside.
+package resolver
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+	"reflect"
+	"sort"
+)
+
+// Kind is how a name was declared
+type Kind int
+
+// The ways a name can be declared
+const (
+	Let Kind = iota
+	Param
+	Import
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Let:
+		return "let"
+	case Param:
+		return "parameter"
+	case Import:
+		return "import"
+	}
+	return "unknown"
+}
+
+// Declaration is a name declared in a scope
+type Declaration struct {
+	Name  string
+	Kind  Kind
+	Scope *Scope
+
+	// Slot is the declaration's index in its scope's Declarations
+	Slot int
+
+	// Defs are the identifiers that bind the name, in source order, and
+	// Values the expressions bound to them, nil for parameters and imports
+	Defs   []*ast.Identifier
+	Values []ast.Expression
+
+	// Refs are the identifiers that read the name
+	Refs []*ast.Identifier
+
+	Exported bool
+
+	// seqs order the defs among the refs as the walk meets them
+	seqs []int
+}
+
+// Scope is the top level of the program or the body of a function or a
+// macro, Node is the function or macro literal and nil for the top level
+type Scope struct {
+	Parent   *Scope
+	Node     ast.Expression
+	Children []*Scope
+
+	// Declarations are in the order they were first bound, a declaration's
+	// Slot is its index
+	Declarations []*Declaration
+
+	names map[string]*Declaration
+}
+
+func newScope(parent *Scope, node ast.Expression) *Scope {
+	s := &Scope{Parent: parent, Node: node, names: make(map[string]*Declaration)}
+	if parent != nil {
+		parent.Children = append(parent.Children, s)
+	}
+	return s
+}
+
+// Lookup finds the declaration a name refers to in this scope or the ones
+// around it, depth is the number of scopes out it was found
+func (s *Scope) Lookup(name string) (decl *Declaration, depth int) {
+	for ; s != nil; s = s.Parent {
+		if decl, ok := s.names[name]; ok {
+			return decl, depth
+		}
+		depth++
+	}
+	return nil, 0
+}
+
+// Binding is where an identifier's name is declared, Depth scopes out from
+// the identifier in slot Declaration.Slot
+type Binding struct {
+	Declaration *Declaration
+	Depth       int
+
+	// Scope is the scope the identifier is in
+	Scope *Scope
+
+	// Def is the index in Declaration.Defs of the definition the identifier
+	// sees: its own for a def, the latest one the walk met before a ref or
+	// the first for refs before any, like a function calling one declared
+	// after it
+	Def int
+}
+
+// Error is a problem with a name, at the position of its identifier
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Result is what the resolver found
+type Result struct {
+	Root *Scope
+
+	// Scopes maps each function literal to the scope of its body
+	Scopes map[*ast.FunctionLiteral]*Scope
+
+	// Declarations are every declaration in the order it was first bound
+	Declarations []*Declaration
+
+	// Bindings maps each identifier that declares or reads a name to its
+	// declaration. Property names in member expressions aren't looked up in
+	// scope and have no binding.
+	Bindings map[*ast.Identifier]Binding
+
+	// Unresolved are identifiers not declared in the program, they are
+	// either predeclared or undefined
+	Unresolved []*ast.Identifier
+
+	// Errors are undefined and duplicate names, in source order
+	Errors []Error
+}
+
+// Resolve links the identifiers in a program, predeclared reports whether a
+// name the program doesn't declare is defined anyway, like a builtin
+func Resolve(program *ast.Program, predeclared func(name string) bool) *Result {
+	r := &resolver{Result: Result{
+		Root:     newScope(nil, nil),
+		Scopes:   make(map[*ast.FunctionLiteral]*Scope),
+		Bindings: make(map[*ast.Identifier]Binding),
+	}}
+	r.walk(program, r.Root)
+
+	for _, ref := range r.refs {
+		decl, depth := ref.scope.Lookup(ref.ident.Value)
+		if decl != nil && depth == 0 && decl.seqs[0] > ref.seq && ref.scope.Parent != nil {
+			// not bound here yet, the evaluator looks outside
+			if outer, d := ref.scope.Parent.Lookup(ref.ident.Value); outer != nil {
+				decl, depth = outer, d+1
+			}
+		}
+		if decl == nil {
+			r.Unresolved = append(r.Unresolved, ref.ident)
+			if !predeclared(ref.ident.Value) {
+				r.errorf(ref.ident.Token, "undefined: %s", ref.ident.Value)
+			}
+			continue
+		}
+		def := 0
+		for i, seq := range decl.seqs {
+			if seq < ref.seq {
+				def = i
+			}
+		}
+		decl.Refs = append(decl.Refs, ref.ident)
+		r.Bindings[ref.ident] = Binding{Declaration: decl, Depth: depth, Scope: ref.scope, Def: def}
+	}
+
+	sort.SliceStable(r.Errors, func(i, j int) bool {
+		a, b := r.Errors[i], r.Errors[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return &r.Result
+}
+
+type ref struct {
+	ident *ast.Identifier
+	scope *Scope
+	seq   int
+}
+
+type resolver struct {
+	Result
+	refs []ref
+	seq  int
+}
+
+func (r *resolver) errorf(tok token.Token, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, Error{
+		Message: fmt.Sprintf(format, args...),
+		Line:    tok.Line,
+		Column:  tok.Column,
+	})
+}
+
+func (r *resolver) declare(s *Scope, kind Kind, ident *ast.Identifier, value ast.Expression) *Declaration {
+	decl, ok := s.names[ident.Value]
+	if !ok {
+		decl = &Declaration{Name: ident.Value, Kind: kind, Scope: s, Slot: len(s.Declarations)}
+		s.names[ident.Value] = decl
+		s.Declarations = append(s.Declarations, decl)
+		r.Declarations = append(r.Declarations, decl)
+	}
+	r.seq++
+	r.Bindings[ident] = Binding{Declaration: decl, Scope: s, Def: len(decl.Defs)}
+	decl.Defs = append(decl.Defs, ident)
+	decl.Values = append(decl.Values, value)
+	decl.seqs = append(decl.seqs, r.seq)
+	return decl
+}
+
+func (r *resolver) walk(node ast.Node, s *Scope) {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		// statements that failed to parse
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			r.walk(stmt, s)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			r.walk(stmt, s)
+		}
+	case *ast.LetStatement:
+		r.walk(node.Value, s)
+		if node.Name != nil {
+			r.declare(s, Let, node.Name, node.Value)
+		}
+	case *ast.ExportStatement:
+		r.walk(node.Statement, s)
+		if node.Statement == nil || node.Statement.Name == nil {
+			return
+		}
+		if s != r.Root {
+			r.errorf(node.Token, "export is only allowed at the top level of a module")
+			return
+		}
+		decl, _ := s.Lookup(node.Statement.Name.Value)
+		if decl.Exported {
+			r.errorf(node.Statement.Name.Token, "%s exported more than once", decl.Name)
+		}
+		decl.Exported = true
+	case *ast.ImportStatement:
+		if node.Alias == nil {
+			return
+		}
+		if decl, ok := s.names[node.Alias.Value]; ok && decl.Kind == Import {
+			r.errorf(node.Alias.Token, "%s imported more than once", node.Alias.Value)
+		}
+		r.declare(s, Import, node.Alias, nil)
+	case *ast.ReturnStatement:
+		r.walk(node.ReturnValue, s)
+	case *ast.ExpressionStatement:
+		r.walk(node.Expression, s)
+	case *ast.Identifier:
+		r.seq++
+		r.refs = append(r.refs, ref{ident: node, scope: s, seq: r.seq})
+	case *ast.FunctionLiteral:
+		inner := newScope(s, node)
+		r.Scopes[node] = inner
+		for _, param := range node.Parameters {
+			if _, ok := inner.names[param.Value]; ok {
+				r.errorf(param.Token, "duplicate parameter %s", param.Value)
+			}
+			r.declare(inner, Param, param, nil)
+		}
+		r.walk(node.Body, inner)
+	case *ast.MacroLiteral:
+		inner := newScope(s, node)
+		for _, param := range node.Parameters {
+			if _, ok := inner.names[param.Value]; ok {
+				r.errorf(param.Token, "duplicate parameter %s", param.Value)
+			}
+			r.declare(inner, Param, param, nil)
+		}
+		r.walk(node.Body, inner)
+	case *ast.PrefixExpression:
+		r.walk(node.Right, s)
+	case *ast.InfixExpression:
+		r.walk(node.Left, s)
+		r.walk(node.Right, s)
+	case *ast.IfExpression:
+		r.walk(node.Condition, s)
+		r.walk(node.Consequence, s)
+		r.walk(node.Alternative, s)
+	case *ast.WhileExpression:
+		r.walk(node.Test, s)
+		r.walk(node.Body, s)
+	case *ast.CallExpression:
+		r.walk(node.Function, s)
+		if code, ok := ast.Quoted(node); ok {
+			// quoted code isn't run, only what it unquotes
+			ast.Inspect(code, func(n ast.Node) bool {
+				if arg, ok := ast.Unquoted(n); ok {
+					r.walk(arg, s)
+					return false
+				}
+				return true
+			})
+			return
+		}
+		for _, arg := range node.Arguments {
+			r.walk(arg, s)
+		}
+	case *ast.IndexExpression:
+		r.walk(node.Left, s)
+		r.walk(node.Index, s)
+	case *ast.SliceExpression:
+		r.walk(node.Left, s)
+		r.walk(node.Start, s)
+		r.walk(node.End, s)
+		r.walk(node.Step, s)
+	case *ast.MemberExpression:
+		// the property is looked up in the object, not in scope
+		r.walk(node.Object, s)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.walk(el, s)
+		}
+	case *ast.HashLiteral:
+		for _, key := range node.Keys {
+			r.walk(key, s)
+			r.walk(node.Pairs[key], s)
+		}
+	}
+}
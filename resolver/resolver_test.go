@@ -0,0 +1,141 @@
+package resolver
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parser errors %v", input, p.Errors())
+	}
+	return program
+}
+
+func builtin(name string) bool {
+	return name == "puts" || name == "len" || name == "quote" || name == "unquote"
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; puts(x);", nil},
+		{"puts(y);", []string{"1:6: undefined: y"}},
+		{"let f = fn() { g() }; let g = fn() { 1 };", nil},
+		{"let f = fn(a) { let b = a; b }; b;", []string{"1:33: undefined: b"}},
+		{"let x = 1; let x = x + 1;", nil},
+		{"let f = fn(a, b, a) { a };", []string{"1:18: duplicate parameter a"}},
+		{`import "a" as m; import "b" as m;`, []string{"1:32: m imported more than once"}},
+		{"export let x = 1; export let x = 2;", []string{"1:30: x exported more than once"}},
+		{"let f = fn() { export let x = 1; };", []string{"1:16: export is only allowed at the top level of a module"}},
+		{"let h = {}; h.missing; puts(h.key);", nil},
+		{"if (true) { puts(z) } else { len(w) }", []string{"1:18: undefined: z", "1:34: undefined: w"}},
+		{"let m = macro(a) { quote(unquote(a) + x) };", nil},
+		{"let m = macro(a, a) { a };", []string{"1:18: duplicate parameter a"}},
+		{"quote(a + unquote(b));", []string{"1:19: undefined: b"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, err := range Resolve(parse(t, tt.input), builtin).Errors {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: wrong errors.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBindings(t *testing.T) {
+	program := parse(t, `
+let x = 1;
+let add = fn(a, b) {
+	let sum = a + b;
+	fn(c) { sum + c + x }
+};
+let x = 2;
+puts(add);`)
+	result := Resolve(program, builtin)
+
+	tests := []struct {
+		name  string
+		line  int
+		kind  Kind
+		slot  int
+		depth int
+		def   int
+	}{
+		{"x", 2, Let, 0, 0, 0},
+		{"add", 3, Let, 1, 0, 0},
+		{"a", 3, Param, 0, 0, 0},
+		{"a", 4, Param, 0, 0, 0},
+		{"sum", 4, Let, 2, 0, 0},
+		{"c", 5, Param, 0, 0, 0},
+		{"sum", 5, Let, 2, 1, 0},
+		{"x", 5, Let, 0, 2, 0},
+		{"x", 7, Let, 0, 0, 1},
+		{"add", 8, Let, 1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		var found *Binding
+		for ident, binding := range result.Bindings {
+			if ident.Value == tt.name && ident.Token.Line == tt.line {
+				binding := binding
+				found = &binding
+			}
+		}
+		if found == nil {
+			t.Errorf("no binding for %s on line %d", tt.name, tt.line)
+			continue
+		}
+		decl := found.Declaration
+		if decl.Kind != tt.kind || decl.Slot != tt.slot || found.Depth != tt.depth {
+			t.Errorf("%s on line %d: expected %s in slot %d at depth %d, got %s in slot %d at depth %d",
+				tt.name, tt.line, tt.kind, tt.slot, tt.depth, decl.Kind, decl.Slot, found.Depth)
+		}
+		if found.Def != tt.def {
+			t.Errorf("%s on line %d: expected definition %d, got %d", tt.name, tt.line, tt.def, found.Def)
+		}
+	}
+
+	x := result.Root.Declarations[0]
+	if len(x.Defs) != 2 || len(x.Refs) != 1 {
+		t.Errorf("x should have 2 definitions and 1 reference, got %d and %d", len(x.Defs), len(x.Refs))
+	}
+	if len(result.Unresolved) != 1 || result.Unresolved[0].Value != "puts" {
+		t.Errorf("expected puts to be the only unresolved name, got %v", result.Unresolved)
+	}
+	if len(result.Scopes) != 2 {
+		t.Errorf("expected 2 function scopes, got %d", len(result.Scopes))
+	}
+	if len(result.Root.Children) != 1 || len(result.Root.Children[0].Children) != 1 {
+		t.Errorf("expected the function scopes to nest")
+	}
+}
+
+func TestReadBeforeShadowingLet(t *testing.T) {
+	program := parse(t, `let x = 1;
+let f = fn() { puts(x); let x = 2; puts(x) };`)
+	result := Resolve(program, builtin)
+
+	outer := result.Root.Declarations[0]
+	if len(outer.Refs) != 1 || outer.Refs[0].Token.Column != 21 {
+		t.Fatalf("the read before the local let should see the outer x, got refs %v", outer.Refs)
+	}
+	if b := result.Bindings[outer.Refs[0]]; b.Depth != 1 {
+		t.Errorf("expected the outer x at depth 1, got %d", b.Depth)
+	}
+
+	local := result.Declarations[1]
+	if local.Name != "x" || local.Scope == result.Root || len(local.Refs) != 1 || local.Refs[0].Token.Column != 41 {
+		t.Errorf("the read after the local let should see it, got %+v", local)
+	}
+}
@@ -0,0 +1,37 @@
+// Package std is the monkey standard library. Its modules are written in
+// monkey and embedded into the binary, they are imported as "std/<name>".
+package std
+
+import (
+	"embed"
+	"sort"
+	"strings"
+)
+
+//go:embed *.mky
+var files embed.FS
+
+// Prefix starts every import path that refers to the standard library
+const Prefix = "std/"
+
+// Source returns the source of a standard library module by name, eg. "strings"
+func Source(name string) (string, bool) {
+	data, err := files.ReadFile(strings.TrimSuffix(name, ".mky") + ".mky")
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Names lists the standard library modules
+func Names() []string {
+	entries, _ := files.ReadDir(".")
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".mky"))
+	}
+	sort.Strings(names)
+
+	return names
+}
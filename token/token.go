@@ -1,5 +1,7 @@
 package token
 
+import "sort"
+
 //Type is a type of token
 type Type string
 
@@ -7,6 +9,11 @@ type Type string
 type Token struct {
 	Type    Type
 	Literal string
+
+	// Line and Column are where the token starts, both count from 1 and the
+	// column counts bytes
+	Line   int
+	Column int
 }
 
 //New => Token constructor
@@ -18,10 +25,15 @@ const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
+	// COMMENT is never returned by the lexer, comments are kept aside for
+	// tools that print source back out
+	COMMENT = "COMMENT"
+
 	//Identifiers + literals
 
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	// Operators
@@ -38,12 +50,14 @@ const (
 
 	EQ    = "=="
 	NOTEQ = "!="
+	ARROW = "->"
 
 	//Delimiters
 
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -63,6 +77,9 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	WHILE    = "WHILE"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	MACRO    = "MACRO"
 )
 
 var keywords = map[string]Type{
@@ -75,6 +92,19 @@ var keywords = map[string]Type{
 	"return": RETURN,
 	"while":  WHILE,
 	"null":   NULL,
+	"import": IMPORT,
+	"export": EXPORT,
+	"macro":  MACRO,
+}
+
+//Keywords returns the language's keywords, sorted
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 //LookupIdent cheks if identifier is a keyword
@@ -0,0 +1,504 @@
+// Package typecheck finds type errors in monkey programs before they run.
+// Checking is gradual: annotations like let x: int = 5 and
+// fn(a: int) -> bool say what a value must be, other types are inferred
+// from literals and operators where it is simple to, and a value nothing is
+// known about is never an error.
+package typecheck
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/resolver"
+	"monkey/token"
+	"reflect"
+	"sort"
+)
+
+// Error is a type error at the position of the expression it is about
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Result is what the checker found
+type Result struct {
+	// Types holds the type of each expression that was checked
+	Types map[ast.Expression]*Type
+
+	// Errors are in source order
+	Errors []Error
+}
+
+// Check checks the types in a program
+func Check(program *ast.Program) *Result {
+	c := &checker{
+		Result:   Result{Types: make(map[ast.Expression]*Type)},
+		resolved: resolver.Resolve(program, func(string) bool { return true }),
+		names:    make(map[*resolver.Declaration]*binding),
+	}
+	c.statements(program.Statements)
+
+	sort.SliceStable(c.Errors, func(i, j int) bool {
+		a, b := c.Errors[i], c.Errors[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return &c.Result
+}
+
+// binding is what is known about a name, a declared type comes from an
+// annotation and holds for every let of the name in its scope
+type binding struct {
+	typ      *Type
+	declared bool
+}
+
+// function is the function whose body is being checked
+type function struct {
+	returns *Type // the annotated return type, nil without one
+	results *Type // the types of the values returned so far
+}
+
+type checker struct {
+	Result
+	resolved *resolver.Result
+	// names holds what is known about the declarations bound so far
+	names    map[*resolver.Declaration]*binding
+	function *function
+}
+
+// declaration is what an identifier that binds a name declares
+func (c *checker) declaration(ident *ast.Identifier) *resolver.Declaration {
+	return c.resolved.Bindings[ident].Declaration
+}
+
+func (c *checker) errorf(tok token.Token, format string, args ...interface{}) {
+	c.Errors = append(c.Errors, Error{
+		Message: fmt.Sprintf(format, args...),
+		Line:    tok.Line,
+		Column:  tok.Column,
+	})
+}
+
+// annotation is the type an annotation names
+func (c *checker) annotation(ta *ast.TypeAnnotation) *Type {
+	if ta == nil {
+		return nil
+	}
+	typ, ok := named[ta.Name]
+	if !ok {
+		c.errorf(ta.Token, "unknown type %s", ta.Name)
+		return anyType
+	}
+	return typ
+}
+
+// statements checks a list of statements and returns the type of the value of
+// the last one
+func (c *checker) statements(stmts []ast.Statement) *Type {
+	result := anyType
+	for _, stmt := range stmts {
+		result = c.statement(stmt)
+	}
+	return result
+}
+
+func isNil(node ast.Node) bool {
+	return node == nil || reflect.ValueOf(node).IsNil()
+}
+
+func (c *checker) statement(stmt ast.Statement) *Type {
+	if isNil(stmt) {
+		// statements that failed to parse
+		return anyType
+	}
+
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(stmt)
+	case *ast.ExportStatement:
+		c.statement(stmt.Statement)
+	case *ast.ImportStatement:
+		if stmt.Alias != nil {
+			c.names[c.declaration(stmt.Alias)] = &binding{typ: moduleType}
+		}
+	case *ast.ReturnStatement:
+		c.returned(stmt.ReturnValue, stmt.Token)
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+	}
+	return anyType
+}
+
+func (c *checker) let(stmt *ast.LetStatement) {
+	name, decl := stmt.Name.Value, c.declaration(stmt.Name)
+	declared := c.annotation(stmt.Type)
+	existing, rebinding := c.names[decl]
+	if declared == nil && rebinding && existing.declared {
+		declared = existing.typ
+	}
+
+	// a function is bound before its body is checked so it can call itself
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && !rebinding {
+		c.names[decl] = &binding{typ: c.signature(fn)}
+	}
+
+	value := c.expression(stmt.Value)
+	switch {
+	case declared != nil:
+		if !assignable(declared, value) {
+			c.errorf(tokenOf(stmt.Value), "cannot use %s as %s in let %s", value, declared, name)
+		}
+		c.names[decl] = &binding{typ: declared, declared: true}
+	case rebinding && !existing.declared && existing.typ.Kind != value.Kind:
+		// a name that holds different kinds of value could hold either
+		c.names[decl] = &binding{typ: anyType}
+	default:
+		c.names[decl] = &binding{typ: value}
+	}
+}
+
+// returned checks a value returned from the function being checked
+func (c *checker) returned(exp ast.Expression, tok token.Token) {
+	typ := c.expression(exp)
+	if c.function == nil {
+		return
+	}
+	if c.function.returns != nil && !assignable(c.function.returns, typ) {
+		c.errorf(tok, "cannot use %s as %s in return", typ, c.function.returns)
+	}
+	c.function.results = join(c.function.results, typ)
+}
+
+// signature is the type of a function from its annotations
+func (c *checker) signature(fn *ast.FunctionLiteral) *Type {
+	typ := &Type{Kind: Func, Params: make([]*Type, len(fn.Parameters)), Result: anyType}
+	for i := range fn.Parameters {
+		typ.Params[i] = anyType
+		if ta := fn.ParameterType(i); ta != nil {
+			typ.Params[i] = c.annotation(ta)
+		}
+	}
+	if fn.ReturnType != nil {
+		typ.Result = c.annotation(fn.ReturnType)
+	}
+	return typ
+}
+
+func (c *checker) expression(exp ast.Expression) *Type {
+	if isNil(exp) {
+		return anyType
+	}
+	typ := c.infer(exp)
+	c.Types[exp] = typ
+	return typ
+}
+
+func (c *checker) infer(exp ast.Expression) *Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return intType
+	case *ast.FloatLiteral:
+		return floatType
+	case *ast.StringLiteral:
+		return stringType
+	case *ast.Boolean:
+		return boolType
+	case *ast.Null:
+		return nullType
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.expression(el)
+		}
+		return arrayType
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			c.expression(key)
+			c.expression(exp.Pairs[key])
+		}
+		return hashType
+	case *ast.Identifier:
+		return c.identifier(exp)
+	case *ast.PrefixExpression:
+		return c.prefix(exp)
+	case *ast.InfixExpression:
+		return c.infix(exp)
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		consequence := c.block(exp.Consequence)
+		if exp.Alternative == nil {
+			return anyType
+		}
+		return join(consequence, c.block(exp.Alternative))
+	case *ast.WhileExpression:
+		c.expression(exp.Test)
+		c.block(exp.Body)
+		return anyType
+	case *ast.FunctionLiteral:
+		return c.functionLiteral(exp)
+	case *ast.CallExpression:
+		if _, ok := ast.Quoted(exp); ok {
+			// quoted code is a value, it isn't run
+			return anyType
+		}
+		return c.call(exp)
+	case *ast.MacroLiteral:
+		return anyType
+	case *ast.IndexExpression:
+		return c.index(exp)
+	case *ast.SliceExpression:
+		left := c.expression(exp.Left)
+		for _, bound := range []ast.Expression{exp.Start, exp.End, exp.Step} {
+			if typ := c.expression(bound); bound != nil && typ.known() && typ.Kind != Int {
+				c.errorf(exp.Token, "slice indices must be int, got %s", typ)
+			}
+		}
+		if left.Kind == Array || left.Kind == String {
+			return left
+		}
+		if left.known() {
+			c.errorf(exp.Token, "slice not supported: %s", left)
+		}
+		return anyType
+	case *ast.MemberExpression:
+		c.expression(exp.Object)
+		return anyType
+	}
+	return anyType
+}
+
+func (c *checker) block(block *ast.BlockStatement) *Type {
+	if block == nil {
+		return anyType
+	}
+	return c.statements(block.Statements)
+}
+
+func (c *checker) identifier(ident *ast.Identifier) *Type {
+	if found, ok := c.resolved.Bindings[ident]; ok {
+		b, ok := c.names[found.Declaration]
+		if !ok {
+			// used before it is bound, like a function calling one
+			// declared after it
+			return anyType
+		}
+		// a function reads names from outside when it is called, by then
+		// they may have been bound again to another kind of value
+		if found.Depth > 0 && !b.declared && b.typ.Kind != Func {
+			return anyType
+		}
+		return b.typ
+	}
+	if _, ok := evaluator.Builtin(ident.Value); ok {
+		result, ok := builtinResults[ident.Value]
+		if !ok {
+			result = anyType
+		}
+		return &Type{Kind: Func, Result: result}
+	}
+	if constant, ok := evaluator.Constant(ident.Value); ok {
+		return typeOf(constant)
+	}
+	return anyType
+}
+
+// typeOf is the type of a value known before the program runs
+func typeOf(obj object.Object) *Type {
+	switch obj.Type() {
+	case object.IntegerObj:
+		return intType
+	case object.FloatObj:
+		return floatType
+	case object.StringObj:
+		return stringType
+	case object.BooleanObj:
+		return boolType
+	}
+	return anyType
+}
+
+func (c *checker) prefix(exp *ast.PrefixExpression) *Type {
+	right := c.expression(exp.Right)
+	switch exp.Operator {
+	case "!":
+		return boolType
+	case "-":
+		if isNumber(right) {
+			return right
+		}
+		if right.known() {
+			c.errorf(exp.Token, "unknown operator: -%s", right)
+		}
+	}
+	return anyType
+}
+
+// infix follows the rules of the evaluator's infix expressions
+func (c *checker) infix(exp *ast.InfixExpression) *Type {
+	left := c.expression(exp.Left)
+	right := c.expression(exp.Right)
+	op := exp.Operator
+	comparison := op == "<" || op == ">" || op == "==" || op == "!="
+
+	switch {
+	case !left.known() || !right.known():
+		if comparison {
+			return boolType
+		}
+		return anyType
+	case isNumber(left) && isNumber(right):
+		if comparison {
+			return boolType
+		}
+		if left.Kind == Int && right.Kind == Int {
+			return intType
+		}
+		return floatType
+	case left.Kind == String && right.Kind == String:
+		if comparison {
+			return boolType
+		}
+		if op == "+" {
+			return stringType
+		}
+	case left.Kind != right.Kind:
+		c.errorf(exp.Token, "type mismatch: %s %s %s", left, op, right)
+		return anyType
+	case op == "==" || op == "!=":
+		return boolType
+	}
+	c.errorf(exp.Token, "unknown operator: %s %s %s", left, op, right)
+	return anyType
+}
+
+func (c *checker) functionLiteral(fn *ast.FunctionLiteral) *Type {
+	typ := c.signature(fn)
+
+	outerFunction := c.function
+	c.function = &function{}
+	if fn.ReturnType != nil {
+		c.function.returns = typ.Result
+	}
+	for i, param := range fn.Parameters {
+		c.names[c.declaration(param)] = &binding{typ: typ.Params[i], declared: fn.ParameterType(i) != nil}
+	}
+
+	// the value of the last statement is returned too
+	if body := fn.Body; body != nil && len(body.Statements) != 0 {
+		stmts := body.Statements
+		c.statements(stmts[:len(stmts)-1])
+		if last, ok := stmts[len(stmts)-1].(*ast.ExpressionStatement); ok {
+			c.returned(last.Expression, last.Token)
+		} else {
+			c.statement(stmts[len(stmts)-1])
+		}
+	}
+
+	if fn.ReturnType == nil && c.function.results != nil {
+		typ.Result = c.function.results
+	}
+	c.function = outerFunction
+	return typ
+}
+
+func (c *checker) call(call *ast.CallExpression) *Type {
+	callee := c.expression(call.Function)
+	args := make([]*Type, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	if callee.known() && callee.Kind != Func {
+		c.errorf(tokenOf(call.Function), "not a function: %s", callee)
+		return anyType
+	}
+	if callee.Params == nil {
+		if callee.Result == nil {
+			return anyType
+		}
+		return callee.Result
+	}
+
+	name := "function"
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+	if len(args) != len(callee.Params) {
+		c.errorf(tokenOf(call.Function), "wrong number of arguments to %s. got=%d, want=%d", name, len(args), len(callee.Params))
+		return callee.Result
+	}
+	for i, arg := range args {
+		if !assignable(callee.Params[i], arg) {
+			c.errorf(tokenOf(call.Arguments[i]), "cannot use %s as %s in argument %d to %s", arg, callee.Params[i], i+1, name)
+		}
+	}
+	return callee.Result
+}
+
+func (c *checker) index(exp *ast.IndexExpression) *Type {
+	left := c.expression(exp.Left)
+	index := c.expression(exp.Index)
+	if !left.known() {
+		return anyType
+	}
+
+	switch {
+	case left.Kind == Hash:
+		return anyType
+	case (left.Kind == Array || left.Kind == String) && !index.known():
+		return anyType
+	case left.Kind == Array && index.Kind == Int:
+		return anyType
+	case left.Kind == String && index.Kind == Int:
+		return stringType
+	}
+	c.errorf(exp.Token, "index operator not supported: %s[%s]", left, index)
+	return anyType
+}
+
+// tokenOf is the first token of an expression
+func tokenOf(exp ast.Expression) token.Token {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return tokenOf(exp.Left)
+	case *ast.CallExpression:
+		return tokenOf(exp.Function)
+	case *ast.IndexExpression:
+		return tokenOf(exp.Left)
+	case *ast.SliceExpression:
+		return tokenOf(exp.Left)
+	case *ast.MemberExpression:
+		return tokenOf(exp.Object)
+	case *ast.Identifier:
+		return exp.Token
+	case *ast.IntegerLiteral:
+		return exp.Token
+	case *ast.FloatLiteral:
+		return exp.Token
+	case *ast.StringLiteral:
+		return exp.Token
+	case *ast.Boolean:
+		return exp.Token
+	case *ast.Null:
+		return exp.Token
+	case *ast.ArrayLiteral:
+		return exp.Token
+	case *ast.HashLiteral:
+		return exp.Token
+	case *ast.FunctionLiteral:
+		return exp.Token
+	case *ast.PrefixExpression:
+		return exp.Token
+	case *ast.IfExpression:
+		return exp.Token
+	case *ast.WhileExpression:
+		return exp.Token
+	}
+	return token.Token{}
+}
@@ -0,0 +1,119 @@
+package typecheck
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parser errors %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x: int = 5; let y: float = 1.5; let s: string = \"a\"; let b: bool = !x;", nil},
+		{"let x: int = \"five\";", []string{"1:14: cannot use string as int in let x"}},
+		{"let x: int = 5; let x = true;", []string{"1:25: cannot use bool as int in let x"}},
+		{"let x = 5; let x = true; x + 1;", nil},
+		{"let x: integer = 5;", []string{"1:8: unknown type integer"}},
+		{"let x: any = 5; let x = \"s\";", nil},
+		{"let x: null = null; let f: fn = len; let a: array = [1]; let h: hash = {};", nil},
+		{"1 + \"a\";", []string{"1:3: type mismatch: int + string"}},
+		{"1 + 2.5 < 4; \"a\" + \"b\" == \"ab\";", nil},
+		{"\"a\" - \"b\";", []string{"1:5: unknown operator: string - string"}},
+		{"true + false;", []string{"1:6: unknown operator: bool + bool"}},
+		{"-\"a\";", []string{"1:1: unknown operator: -string"}},
+		{"let x: int = 1 + 2 * 3; let y: float = 1 / 2.0;", nil},
+		{"let x: int = 1 / 2.0;", []string{"1:14: cannot use float as int in let x"}},
+		{"let x: string = \"abc\"[0]; let y: string = \"abc\"[1:];", nil},
+		{"5[0];", []string{"1:2: index operator not supported: int[int]"}},
+		{"[1][\"a\"];", []string{"1:4: index operator not supported: array[string]"}},
+		{"5(1);", []string{"1:1: not a function: int"}},
+		{"let n: int = len(\"abc\"); let s: string = upper(\"a\");", nil},
+		{"let n: string = len(\"abc\");", []string{"1:17: cannot use int as string in let n"}},
+		{"let p: float = PI;", nil},
+		{`import "std/math" as m; let x: int = m.sign(1);`, nil},
+		{`import "std/math" as m; let x: int = m;`, []string{"1:38: cannot use module as int in let x"}},
+		{"let m = macro(a) { quote(1 + unquote(a)) }; let q: int = m(1); quote(1 + \"a\");", nil},
+		{"let f = fn() { len - 1 }; let len = 5; f();", nil},
+		{"let f = fn(x: int) { let g = fn(x) { x + \"a\" }; x + 1 };", nil},
+		{
+			"let x: int = 1; let f = fn() { let a: string = x; let x = \"s\"; let b: string = x; a };",
+			[]string{"1:48: cannot use int as string in let a"},
+		},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, err := range Check(parse(t, tt.input)).Errors {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: wrong errors.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCheckFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let add = fn(a: int, b: int) -> int { a + b }; let x: int = add(1, 2);", nil},
+		{"let add = fn(a: int, b: int) -> int { a + b }; add(1, \"2\");", []string{
+			"1:55: cannot use string as int in argument 2 to add",
+		}},
+		{"let add = fn(a: int, b: int) -> int { a + b }; add(1);", []string{
+			"1:48: wrong number of arguments to add. got=1, want=2",
+		}},
+		{"let f = fn(s: string) -> int { s };", []string{"1:32: cannot use string as int in return"}},
+		{"let f = fn(s: string) -> int { return s; };", []string{"1:32: cannot use string as int in return"}},
+		{"let f = fn(a: int) { a + \"s\" };", []string{"1:24: type mismatch: int + string"}},
+		{"let f = fn(a) { a + \"s\" }; f(1);", nil},
+		{"let f = fn() { 1 }; let s: string = f();", []string{"1:37: cannot use int as string in let s"}},
+		{"let f = fn(x) { if (x) { return 1; } 2 }; let n: int = f(true);", nil},
+		{"let f = fn(x) { if (x) { return 1; } \"two\" }; let n: int = f(true);", nil},
+		{
+			"let fib = fn(n: int) -> int { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(\"x\");",
+			[]string{"1:87: cannot use string as int in argument 1 to fib"},
+		},
+		{"let x = 1; let f = fn() { x + \"s\" }; let x = \"a\"; f();", nil},
+		{"let x: int = 1; let f = fn() { x + \"s\" };", []string{"1:34: type mismatch: int + string"}},
+		{"let f = fn(a: int) { a }; let a = \"s\"; f(a);", []string{"1:42: cannot use string as int in argument 1 to f"}},
+		{"fn(a: int) { a }(\"s\");", []string{"1:18: cannot use string as int in argument 1 to function"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, err := range Check(parse(t, tt.input)).Errors {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: wrong errors.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTypes(t *testing.T) {
+	program := parse(t, `let f = fn(a: int, b) -> bool { true }; f; len; [1, 2]; 1 < 2;`)
+	result := Check(program)
+
+	expected := []string{"fn(int, any) -> bool", "fn", "array", "bool"}
+	for i, want := range expected {
+		exp := program.Statements[i+1].(*ast.ExpressionStatement).Expression
+		if got := result.Types[exp].String(); got != want {
+			t.Errorf("statement %d: wrong type. expected=%q, got=%q", i+1, want, got)
+		}
+	}
+}
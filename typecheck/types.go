@@ -0,0 +1,146 @@
+package typecheck
+
+import "strings"
+
+// Kind is the kind of value a type describes
+type Kind int
+
+// The kinds of value, Any is a value nothing is known about
+const (
+	Any Kind = iota
+	Int
+	Float
+	String
+	Bool
+	Null
+	Array
+	Hash
+	Func
+	Module
+)
+
+var kindNames = map[Kind]string{
+	Any:    "any",
+	Int:    "int",
+	Float:  "float",
+	String: "string",
+	Bool:   "bool",
+	Null:   "null",
+	Array:  "array",
+	Hash:   "hash",
+	Func:   "fn",
+	Module: "module",
+}
+
+// Type is what is known about a value. A function's Params are nil when its
+// parameters aren't known.
+type Type struct {
+	Kind   Kind
+	Params []*Type
+	Result *Type
+}
+
+var (
+	anyType    = &Type{Kind: Any}
+	intType    = &Type{Kind: Int}
+	floatType  = &Type{Kind: Float}
+	stringType = &Type{Kind: String}
+	boolType   = &Type{Kind: Bool}
+	nullType   = &Type{Kind: Null}
+	arrayType  = &Type{Kind: Array}
+	hashType   = &Type{Kind: Hash}
+	funcType   = &Type{Kind: Func, Result: anyType}
+	moduleType = &Type{Kind: Module}
+)
+
+// named are the types an annotation can name
+var named = map[string]*Type{
+	"any":    anyType,
+	"int":    intType,
+	"float":  floatType,
+	"string": stringType,
+	"bool":   boolType,
+	"null":   nullType,
+	"array":  arrayType,
+	"hash":   hashType,
+	"fn":     funcType,
+	"module": moduleType,
+}
+
+func (t *Type) String() string {
+	if t.Kind != Func || t.Params == nil {
+		return kindNames[t.Kind]
+	}
+
+	params := make([]string, len(t.Params))
+	for i, param := range t.Params {
+		params[i] = param.String()
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + t.Result.String()
+}
+
+// known reports whether anything is known about a value of this type
+func (t *Type) known() bool {
+	return t.Kind != Any
+}
+
+// assignable reports whether a value of type from can be used where the type
+// to is expected, a value nothing is known about always can
+func assignable(to, from *Type) bool {
+	return !to.known() || !from.known() || to.Kind == from.Kind
+}
+
+// join is the type of a value that can come from either a or b
+func join(a, b *Type) *Type {
+	if a == nil {
+		return b
+	}
+	if a.Kind == b.Kind && a.Kind != Func {
+		return a
+	}
+	return anyType
+}
+
+func isNumber(t *Type) bool {
+	return t.Kind == Int || t.Kind == Float
+}
+
+// builtinResults are the types builtins that always return the same kind of
+// value return, other builtins return any
+var builtinResults = map[string]*Type{
+	"len":         intType,
+	"index_of":    intType,
+	"gcd":         intType,
+	"lcm":         intType,
+	"int":         intType,
+	"float":       floatType,
+	"sqrt":        floatType,
+	"sin":         floatType,
+	"cos":         floatType,
+	"tan":         floatType,
+	"asin":        floatType,
+	"acos":        floatType,
+	"atan":        floatType,
+	"log":         floatType,
+	"exp":         floatType,
+	"type":        stringType,
+	"join":        stringType,
+	"trim":        stringType,
+	"upper":       stringType,
+	"lower":       stringType,
+	"repeat":      stringType,
+	"substr":      stringType,
+	"format":      stringType,
+	"json_encode": stringType,
+	"keys":        arrayType,
+	"values":      arrayType,
+	"items":       arrayType,
+	"split":       arrayType,
+	"chars":       arrayType,
+	"bool":        boolType,
+	"has":         boolType,
+	"contains":    boolType,
+	"starts_with": boolType,
+	"ends_with":   boolType,
+	"exists":      boolType,
+}
@@ -0,0 +1,153 @@
+package vet
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/resolver"
+	"monkey/token"
+	"strings"
+)
+
+// Unused reports let bindings that are never read. Exported bindings and
+// names starting with _ are left alone.
+var Unused = &Check{
+	Name: "unused",
+	Doc:  "report let bindings that are never used",
+	Run: func(pass *Pass) {
+		for _, decl := range pass.Names.Declarations {
+			if decl.Kind != resolver.Let || decl.Exported || len(decl.Refs) != 0 || strings.HasPrefix(decl.Name, "_") {
+				continue
+			}
+			pass.Reportf(decl.Defs[0].Token, "%s declared and not used", decl.Name)
+		}
+	},
+}
+
+// Undefined reports identifiers that aren't bound anywhere they could be
+// seen from, which the evaluator fails on when it reaches them
+var Undefined = &Check{
+	Name: "undefined",
+	Doc:  "report names that are not defined",
+	Run: func(pass *Pass) {
+		for _, ident := range pass.Names.Unresolved {
+			if !pass.Predeclared(ident.Value) {
+				pass.Reportf(ident.Token, "undefined: %s", ident.Value)
+			}
+		}
+	},
+}
+
+// Shadow reports bindings that hide a builtin function or constant
+var Shadow = &Check{
+	Name: "shadow",
+	Doc:  "report bindings that hide a builtin",
+	Run: func(pass *Pass) {
+		for _, decl := range pass.Names.Declarations {
+			if _, ok := evaluator.Builtin(decl.Name); ok {
+				pass.Reportf(decl.Defs[0].Token, "%s shadows the builtin function", decl.Name)
+			} else if _, ok := evaluator.Constant(decl.Name); ok {
+				pass.Reportf(decl.Defs[0].Token, "%s shadows the builtin constant", decl.Name)
+			}
+		}
+	},
+}
+
+// Unreachable reports statements after a return, or after an if whose
+// branches both return
+var Unreachable = &Check{
+	Name: "unreachable",
+	Doc:  "report code that can never run",
+	Run: func(pass *Pass) {
+		ast.Inspect(pass.Program, func(node ast.Node) bool {
+			var stmts []ast.Statement
+			switch node := node.(type) {
+			case *ast.Program:
+				stmts = node.Statements
+			case *ast.BlockStatement:
+				stmts = node.Statements
+			}
+			for i := 1; i < len(stmts); i++ {
+				if returns(stmts[i-1]) {
+					pass.Reportf(statementToken(stmts[i]), "unreachable code")
+					break
+				}
+			}
+			return true
+		})
+	},
+}
+
+// returns reports whether a statement always returns from its function
+func returns(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		exp, ok := stmt.Expression.(*ast.IfExpression)
+		return ok && exp.Alternative != nil && blockReturns(exp.Consequence) && blockReturns(exp.Alternative)
+	}
+	return false
+}
+
+func blockReturns(block *ast.BlockStatement) bool {
+	for _, stmt := range block.Statements {
+		if returns(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Arity reports calls with the wrong number of arguments to functions whose
+// parameters are known: function literals and names only ever bound to one
+var Arity = &Check{
+	Name: "arity",
+	Doc:  "report calls with the wrong number of arguments",
+	Run: func(pass *Pass) {
+		ast.Inspect(pass.Program, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpression)
+			if !ok {
+				return true
+			}
+
+			var fn *ast.FunctionLiteral
+			var name string
+			var at token.Token
+			switch callee := call.Function.(type) {
+			case *ast.FunctionLiteral:
+				fn, name, at = callee, "function", callee.Token
+			case *ast.Identifier:
+				fn, name, at = knownFunction(pass.Names.Bindings[callee].Declaration), callee.Value, callee.Token
+			}
+			if fn != nil && len(call.Arguments) != len(fn.Parameters) {
+				pass.Reportf(at, "wrong number of arguments to %s. got=%d, want=%d",
+					name, len(call.Arguments), len(fn.Parameters))
+			}
+			return true
+		})
+	},
+}
+
+func knownFunction(decl *resolver.Declaration) *ast.FunctionLiteral {
+	if decl == nil || decl.Kind != resolver.Let || len(decl.Defs) != 1 {
+		return nil
+	}
+	fn, _ := decl.Values[0].(*ast.FunctionLiteral)
+	return fn
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.ImportStatement:
+		return stmt.Token
+	case *ast.ExportStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
@@ -0,0 +1,111 @@
+// Package vet finds likely mistakes in monkey programs without running them.
+// Each kind of mistake is found by a Check, checks can be added to Checks or
+// run on their own.
+package vet
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/resolver"
+	"monkey/token"
+	"sort"
+)
+
+// Diagnostic is a problem found by a check
+type Diagnostic struct {
+	Check   string `json:"check"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+// Check is one analysis, Run reports what it finds through the pass
+type Check struct {
+	Name string
+	Doc  string
+	Run  func(pass *Pass)
+}
+
+// Checks are the checks monkey vet runs, in the order they run
+var Checks = []*Check{
+	Unused,
+	Undefined,
+	Shadow,
+	Unreachable,
+	Arity,
+}
+
+// Lookup finds one of Checks by name
+func Lookup(name string) (*Check, bool) {
+	for _, check := range Checks {
+		if check.Name == name {
+			return check, true
+		}
+	}
+	return nil, false
+}
+
+// Pass is what a check gets to look at while it runs
+type Pass struct {
+	Program *ast.Program
+
+	// Names links the program's identifiers to their declarations
+	Names *resolver.Result
+
+	globals     map[string]bool
+	check       *Check
+	diagnostics []Diagnostic
+}
+
+// Predeclared reports whether a name is quote, unquote, a builtin, a
+// constant or one of the globals the program was checked with
+func (p *Pass) Predeclared(name string) bool {
+	if name == "quote" || name == "unquote" {
+		return true
+	}
+	if _, ok := evaluator.Builtin(name); ok {
+		return true
+	}
+	if _, ok := evaluator.Constant(name); ok {
+		return true
+	}
+	return p.globals[name]
+}
+
+// Reportf reports a problem at a token
+func (p *Pass) Reportf(tok token.Token, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Check:   p.check.Name,
+		Line:    tok.Line,
+		Column:  tok.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Run runs checks over a program and returns what they found sorted by
+// position. globals are names the program can use without defining them,
+// besides the builtins, like ARGS.
+func Run(program *ast.Program, checks []*Check, globals ...string) []Diagnostic {
+	pass := &Pass{Program: program, globals: make(map[string]bool)}
+	for _, name := range globals {
+		pass.globals[name] = true
+	}
+	pass.Names = resolver.Resolve(program, pass.Predeclared)
+
+	for _, check := range checks {
+		pass.check = check
+		check.Run(pass)
+	}
+
+	diagnostics := pass.diagnostics
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return diagnostics
+}
@@ -0,0 +1,83 @@
+package vet
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		check    *Check
+		input    string
+		expected []string
+	}{
+		{Unused, "let x = 1; let y = 2; puts(y);", []string{"1:5: x declared and not used"}},
+		{Unused, "let i = 0; while (i < 3) { let i = i + 1; }", nil},
+		{Unused, "export let x = 1; let _y = 2;", nil},
+		{Unused, "let f = fn(x) { let y = x; 1 }; f(1);", []string{"1:21: y declared and not used"}},
+		{Unused, `import "std/math" as m; let x = m.PI; x;`, nil},
+		{Undefined, "puts(x);", []string{"1:6: undefined: x"}},
+		{Undefined, "let f = fn() { g() }; let g = fn() { 1 }; f();", nil},
+		{Undefined, "let f = fn(a) { a + b }; f(1);", []string{"1:21: undefined: b"}},
+		{Undefined, "len(ARGS) + PI;", nil},
+		{Undefined, "let h = {}; h.name;", nil},
+		{Shadow, "let len = 1; let f = fn(PI) { PI };", []string{
+			"1:5: len shadows the builtin function",
+			"1:25: PI shadows the builtin constant",
+		}},
+		{Unreachable, "let f = fn() { return 1; puts(2); puts(3); };", []string{"1:26: unreachable code"}},
+		{Unreachable, "let f = fn(x) { if (x) { return 1; } else { return 2; } puts(x); };", []string{"1:57: unreachable code"}},
+		{Unreachable, "let f = fn(x) { if (x) { return 1; } puts(x); };", nil},
+		{Arity, "let add = fn(a, b) { a + b }; add(1); add(1, 2);", []string{
+			"1:31: wrong number of arguments to add. got=1, want=2",
+		}},
+		{Arity, "fn(x) { x }(1, 2);", []string{"1:1: wrong number of arguments to function. got=2, want=1"}},
+		{Arity, "let f = fn(a) { a }; let f = fn(a, b) { a }; f(1, 2);", nil},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%q: parser errors %v", tt.input, p.Errors())
+		}
+
+		var got []string
+		for _, d := range Run(program, []*Check{tt.check}, "ARGS") {
+			if d.Check != tt.check.Name {
+				t.Errorf("%q: diagnostic from the wrong check %q", tt.input, d.Check)
+			}
+			got = append(got, d.String())
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s %q: wrong diagnostics.\nexpected=%q\ngot=%q", tt.check.Name, tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRunSortsByPosition(t *testing.T) {
+	program := parser.New(lexer.New("puts(x);\nlet len = 1;")).ParseProgram()
+	diagnostics := Run(program, Checks)
+
+	var checks []string
+	for _, d := range diagnostics {
+		checks = append(checks, d.Check)
+	}
+	expected := []string{"undefined", "unused", "shadow"}
+	if !reflect.DeepEqual(checks, expected) {
+		t.Errorf("wrong order. expected=%v, got=%v", expected, checks)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, check := range Checks {
+		if found, ok := Lookup(check.Name); !ok || found != check {
+			t.Errorf("Lookup(%q) didn't find the check", check.Name)
+		}
+	}
+	if _, ok := Lookup("nope"); ok {
+		t.Errorf("Lookup found a check that doesn't exist")
+	}
+}